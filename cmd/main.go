@@ -2,13 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/akhilbisht798/gocrony/config"
 	"github.com/akhilbisht798/gocrony/internal/auth"
 	"github.com/akhilbisht798/gocrony/internal/cache"
 	"github.com/akhilbisht798/gocrony/internal/db"
 	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/akhilbisht798/gocrony/internal/secrets"
 	"github.com/akhilbisht798/gocrony/internal/server"
 	"github.com/akhilbisht798/gocrony/internal/worker"
 	"github.com/google/uuid"
@@ -17,18 +24,55 @@ import (
 func main() {
 	config.LoadEnv()
 	db.InitDB()
+	if err := db.InitJobDB(); err != nil {
+		log.Println("Error connecting to sql job datasource: ", err)
+	}
 	auth.NewAuth()
+	if err := secrets.CheckConfig(); err != nil {
+		log.Printf("Secrets and webhook signing are unavailable: %v", err)
+	}
 	err := cache.InitRedisClient()
 	if err != nil {
 		log.Panic(err)
 		return
 	}
-	go scheduler.Scheduler()
-	worker := worker.NewWorker(uuid.NewString())
-	go worker.Start(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	elector := scheduler.NewElector(scheduler.InstanceID())
+	go elector.Run(ctx)
+	go scheduler.Scheduler(ctx, elector)
+	go scheduler.StartReaper(ctx, elector)
+	pool := worker.NewPoolFromConfig(uuid.NewString())
+	pool.Start(ctx)
+	go worker.StartRedelivery(ctx)
 
 	port := ":" + config.GetEnv("PORT", "8080")
 
-	server := server.NewServer()
-	server.Run(port)
+	server := server.NewServer(port)
+	go func() {
+		if err := server.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("server error: ", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("shutting down, waiting for in-flight jobs")
+
+	timeout := time.Duration(config.GetEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 60)) * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Error shutting down server: ", err)
+	}
+	if !pool.Wait(shutdownCtx) {
+		log.Println("shutdown deadline reached, unfinished jobs will be redelivered")
+	}
+	if db.JobDB != nil {
+		db.JobDB.Close()
+	}
+	log.Println("shutdown complete")
 }
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/gin-gonic/gin"
+)
+
+func GetStuckJobs(c *gin.Context) {
+	threshold := scheduler.StuckJobThreshold()
+	jobs, err := scheduler.FindStuckJobs(c.Request.Context(), threshold)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch stuck jobs: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"threshold_seconds": int(threshold.Seconds()),
+		"jobs":              jobs,
+	})
+}
+
+func GetSchedulerLeader(c *gin.Context) {
+	status, err := scheduler.CurrentLeader(c.Request.Context())
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch scheduler leader: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"scheduler": status,
+	})
+}
@@ -0,0 +1,297 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/middleware"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DEFAULT_UPCOMING_RUNS = 10
+	MAX_UPCOMING_RUNS     = 100
+)
+
+func CreateCalendar(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+
+	calendar, ok := bindCalendar(c)
+	if !ok {
+		return
+	}
+	calendar.UserID = userId
+	if err := db.DB.Create(&calendar).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to create calendar: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message":  "calendar created",
+		"calendar": calendar,
+	})
+}
+
+// bindCalendar reads and validates a CalendarRequest, it has already
+// responded when it returns false.
+func bindCalendar(c *gin.Context) (models.Calendar, bool) {
+	var req models.CalendarRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid JSON",
+		})
+		return models.Calendar{}, false
+	}
+	if err := validate.Struct(req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "validation failed: " + err.Error(),
+		})
+		return models.Calendar{}, false
+	}
+	calendar := models.Calendar{
+		Name:     req.Name,
+		Timezone: req.Timezone,
+		Dates:    req.Dates,
+		Ranges:   req.Ranges,
+		Windows:  req.Windows,
+	}
+	if err := scheduler.ValidateCalendar(&calendar); err != nil {
+		c.JSON(400, gin.H{
+			"error": "validation failed: " + err.Error(),
+		})
+		return models.Calendar{}, false
+	}
+	return calendar, true
+}
+
+func GetAllCalendars(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var list []models.Calendar
+	if err := db.DB.Where("user_id = ?", userId).Order("name").Find(&list).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch calendars: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"calendars": list,
+	})
+}
+
+func GetCalendar(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var calendar models.Calendar
+	if err := db.DB.First(&calendar, "id = ? AND user_id = ?", c.Param("id"), userId).Error; err != nil {
+		c.JSON(404, gin.H{
+			"error": "calendar not found",
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"calendar": calendar,
+	})
+}
+
+// UpdateCalendar replaces the calendar's rules and reschedules the waiting
+// jobs that use it. A change that would leave one of them with no run at all
+// is refused.
+func UpdateCalendar(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var existing models.Calendar
+	if err := db.DB.First(&existing, "id = ? AND user_id = ?", c.Param("id"), userId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": "calendar not found"})
+		} else {
+			c.JSON(500, gin.H{"error": "failed to fetch calendar: " + err.Error()})
+		}
+		return
+	}
+
+	calendar, ok := bindCalendar(c)
+	if !ok {
+		return
+	}
+	existing.Name = calendar.Name
+	existing.Timezone = calendar.Timezone
+	existing.Dates = calendar.Dates
+	existing.Ranges = calendar.Ranges
+	existing.Windows = calendar.Windows
+
+	// Queued jobs get their next run from the worker, failed ones wait for
+	// their retry, and a next_run already due goes through the misfire
+	// planner with the new rules.
+	var jobs []models.Job
+	err = db.DB.Where("calendar_id = ? AND run_at IS NULL AND (status IS NULL OR status = '' OR status = ?) AND (next_run IS NULL OR next_run > ?)",
+		existing.ID, models.StatusPending, time.Now().UTC()).Find(&jobs).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to update calendar: " + err.Error(),
+		})
+		return
+	}
+	nextRuns := make(map[uuid.UUID]*time.Time, len(jobs))
+	for _, job := range jobs {
+		nextRun, err := scheduler.NextRunWithCalendar(&job, &existing, time.Now())
+		if err != nil {
+			c.JSON(400, gin.H{
+				"error": "validation failed: job " + job.ID.String() + ": " + err.Error(),
+			})
+			return
+		}
+		nextRuns[job.ID] = nextRun
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+		for id, nextRun := range nextRuns {
+			err := tx.Model(&models.Job{}).
+				Where("id = ? AND (status IS NULL OR status = '' OR status = ?)", id, models.StatusPending).
+				Update("next_run", nextRun).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to update calendar: " + err.Error(),
+		})
+		return
+	}
+	scheduler.Wake(c.Request.Context())
+	c.JSON(200, gin.H{
+		"message":  "calendar updated",
+		"calendar": existing,
+	})
+}
+
+func DeleteCalendar(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	id := c.Param("id")
+
+	// Dropping a calendar silently would let its jobs run on the days it
+	// was protecting, so it has to be detached first.
+	var inUse int64
+	if err := db.DB.Model(&models.Job{}).Where("calendar_id = ?", id).Count(&inUse).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to delete calendar: " + err.Error(),
+		})
+		return
+	}
+	if inUse > 0 {
+		c.JSON(409, gin.H{
+			"error": "calendar is used by " + strconv.FormatInt(inUse, 10) + " jobs",
+		})
+		return
+	}
+
+	tx := db.DB.Delete(&models.Calendar{}, "id = ? AND user_id = ?", id, userId)
+	if tx.Error != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to delete calendar: " + tx.Error.Error(),
+		})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(404, gin.H{"error": "calendar not found or not owned by user."})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": "successfully deleted",
+		"id":      id,
+	})
+}
+
+// validateCalendarOwner checks that a job may reference calendarId.
+func validateCalendarOwner(calendarId uuid.UUID, userId uuid.UUID) error {
+	var count int64
+	if err := db.DB.Model(&models.Calendar{}).Where("id = ? AND user_id = ?", calendarId, userId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("calendar not found or not owned by user")
+	}
+	return nil
+}
+
+// GetUpcomingRuns lists the job's next runs and the occurrences its calendar
+// suppresses in between, ?count= sets how many runs (default 10, max 100).
+func GetUpcomingRuns(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var job models.Job
+	if err := db.DB.First(&job, "id = ? AND user_id = ?", c.Param("id"), userId).Error; err != nil {
+		c.JSON(404, gin.H{
+			"error": "job not found",
+		})
+		return
+	}
+
+	count := DEFAULT_UPCOMING_RUNS
+	if raw := c.Query("count"); raw != "" {
+		count, err = strconv.Atoi(raw)
+		if err != nil || count < 1 || count > MAX_UPCOMING_RUNS {
+			c.JSON(400, gin.H{
+				"error": "count must be between 1 and " + strconv.Itoa(MAX_UPCOMING_RUNS),
+			})
+			return
+		}
+	}
+
+	runs, err := scheduler.UpcomingRuns(&job, time.Now(), count)
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to compute upcoming runs: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"runs": runs,
+	})
+}
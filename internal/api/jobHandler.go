@@ -1,14 +1,19 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/akhilbisht798/gocrony/internal/db"
 	"github.com/akhilbisht798/gocrony/internal/middleware"
 	"github.com/akhilbisht798/gocrony/internal/models"
 	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/akhilbisht798/gocrony/internal/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -42,25 +47,104 @@ func CreateNewJob(c *gin.Context) {
 		return
 	}
 
-	job := models.Job{
-		Name:      req.Name,
-		Schedule:  req.Schedule,
-		Type:      req.Type,
-		Payload:   req.Payload,
-		UserID:    userId,
-		Recurring: *req.Recurring,
-		Enabled:   *req.Enabled,
-		Timezone:  req.Timezone,
-	}
-
-	nextRun, err := scheduler.GetNextRun(req.Schedule, req.Timezone)
-	if err != nil {
-		c.JSON(500, gin.H{
-			"error": "failed to create job: " + err.Error(),
+	if err := worker.ValidatePayload(req.Type, req.Payload, req.Templated); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid payload: " + err.Error(),
 		})
 		return
 	}
-	job.NextRun = nextRun
+
+	if req.TimeoutSeconds != nil {
+		if err := validateTimeout(*req.TimeoutSeconds); err != nil {
+			c.JSON(400, gin.H{
+				"error": "validation failed: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.RetryPolicy != nil {
+		if err := scheduler.ValidateRetryPolicy(*req.RetryPolicy); err != nil {
+			c.JSON(400, gin.H{
+				"error": "validation failed: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	if req.CalendarID != nil {
+		if err := validateCalendarOwner(*req.CalendarID, userId); err != nil {
+			c.JSON(400, gin.H{
+				"error": "validation failed: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	job := models.Job{
+		// The id seeds H fields in the schedule, so it is chosen up front.
+		ID:           uuid.New(),
+		CalendarID:   req.CalendarID,
+		Name:         req.Name,
+		Schedule:     req.Schedule,
+		ScheduleKind: req.ScheduleKind,
+		Type:         req.Type,
+		Payload:      req.Payload,
+		Templated:    req.Templated,
+		UserID:       userId,
+		Enabled:      *req.Enabled,
+		Timezone:     req.Timezone,
+		// Interval schedules count from the creation time.
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if req.JitterSeconds != nil {
+		job.JitterSeconds = *req.JitterSeconds
+	}
+
+	// A run_at job runs once at that time, the schedule is not used.
+	if req.RunAt != nil {
+		runAt := req.RunAt.UTC()
+		job.RunAt = &runAt
+		job.NextRun = &runAt
+	} else {
+		job.Recurring = *req.Recurring
+		if err := scheduler.ValidateJitter(&job); err != nil {
+			c.JSON(400, gin.H{
+				"error": "validation failed: " + err.Error(),
+			})
+			return
+		}
+		nextRun, err := scheduler.NextScheduledRun(&job, job.CreatedAt)
+		if err != nil {
+			c.JSON(500, gin.H{
+				"error": "failed to create job: " + err.Error(),
+			})
+			return
+		}
+		job.NextRun = nextRun
+	}
+	if req.TimeoutSeconds != nil {
+		job.TimeoutSeconds = *req.TimeoutSeconds
+	}
+	if req.RetryPolicy != nil {
+		job.RetryPolicy = *req.RetryPolicy
+	}
+	job.AlertEmails = req.AlertEmails
+	if req.AlertAfterFailures != nil {
+		job.AlertAfterFailures = *req.AlertAfterFailures
+	}
+	job.ConcurrencyPolicy = req.ConcurrencyPolicy
+	job.MisfirePolicy = req.MisfirePolicy
+	if req.MaxCatchUp != nil {
+		job.MaxCatchUp = *req.MaxCatchUp
+	}
+	if req.StartingDeadlineSeconds != nil {
+		job.StartingDeadlineSeconds = *req.StartingDeadlineSeconds
+	}
+	if req.DeleteAfterSeconds != nil {
+		job.DeleteAfterSeconds = *req.DeleteAfterSeconds
+	}
 
 	if err := db.DB.Create(&job).Error; err != nil {
 		c.JSON(500, gin.H{
@@ -68,6 +152,7 @@ func CreateNewJob(c *gin.Context) {
 		})
 		return
 	}
+	scheduler.Wake(c.Request.Context())
 
 	c.JSON(200, gin.H{
 		"message": "Job recived",
@@ -75,6 +160,17 @@ func CreateNewJob(c *gin.Context) {
 	})
 }
 
+// validateTimeout checks timeout_seconds against the server maximum, 0 means
+// the server default.
+func validateTimeout(seconds int) error {
+	// Compared in seconds, a huge value would overflow as a Duration.
+	maxTimeout := worker.MaxJobTimeout()
+	if seconds > int(maxTimeout/time.Second) {
+		return fmt.Errorf("timeout_seconds must be at most %d", int(maxTimeout.Seconds()))
+	}
+	return nil
+}
+
 func UpdateJob(c *gin.Context) {
 	userId, err := middleware.ParseUserID(c)
 	if err != nil {
@@ -124,6 +220,25 @@ func UpdateJob(c *gin.Context) {
 		return
 	}
 
+	if req.Type != "" || req.Payload != nil || req.Templated != nil {
+		jobType, payload, templated := existingJob.Type, existingJob.Payload, existingJob.Templated
+		if req.Type != "" {
+			jobType = req.Type
+		}
+		if req.Payload != nil {
+			payload = req.Payload
+		}
+		if req.Templated != nil {
+			templated = *req.Templated
+		}
+		if err := worker.ValidatePayload(jobType, payload, templated); err != nil {
+			c.JSON(400, gin.H{
+				"error": "invalid payload: " + err.Error(),
+			})
+			return
+		}
+	}
+
 	// Build updates map
 	updates := make(map[string]any)
 	shouldRecalculateNextRun := false
@@ -140,6 +255,10 @@ func UpdateJob(c *gin.Context) {
 		updates["payload"] = req.Payload
 	}
 
+	if req.Templated != nil {
+		updates["templated"] = *req.Templated
+	}
+
 	if req.Recurring != nil {
 		updates["recurring"] = *req.Recurring
 	}
@@ -148,16 +267,125 @@ func UpdateJob(c *gin.Context) {
 		updates["enabled"] = *req.Enabled
 	}
 
-	// Handle schedule update
+	if req.TimeoutSeconds != nil {
+		if err := validateTimeout(*req.TimeoutSeconds); err != nil {
+			c.JSON(400, gin.H{
+				"error": "validation failed: " + err.Error(),
+			})
+			return
+		}
+		updates["timeout_seconds"] = *req.TimeoutSeconds
+	}
+
+	// The policy is replaced as a whole, omitted fields go back to defaults.
+	if req.RetryPolicy != nil {
+		if err := scheduler.ValidateRetryPolicy(*req.RetryPolicy); err != nil {
+			c.JSON(400, gin.H{
+				"error": "validation failed: " + err.Error(),
+			})
+			return
+		}
+		updates["retry_max_attempts"] = req.RetryPolicy.MaxAttempts
+		updates["retry_backoff"] = req.RetryPolicy.Backoff
+		updates["retry_initial_delay_seconds"] = req.RetryPolicy.InitialDelaySeconds
+		updates["retry_max_delay_seconds"] = req.RetryPolicy.MaxDelaySeconds
+		updates["retry_jitter_seconds"] = req.RetryPolicy.JitterSeconds
+	}
+
+	// An empty list clears the extra recipients, omitting it keeps them.
+	if req.AlertEmails != nil {
+		encoded, _ := json.Marshal(req.AlertEmails)
+		updates["alert_emails"] = string(encoded)
+	}
+
+	if req.AlertAfterFailures != nil {
+		updates["alert_after_failures"] = *req.AlertAfterFailures
+	}
+
+	if req.ConcurrencyPolicy != "" {
+		updates["concurrency_policy"] = req.ConcurrencyPolicy
+	}
+
+	if req.MisfirePolicy != "" {
+		updates["misfire_policy"] = req.MisfirePolicy
+	}
+
+	if req.MaxCatchUp != nil {
+		updates["max_catch_up"] = *req.MaxCatchUp
+	}
+
+	if req.StartingDeadlineSeconds != nil {
+		updates["starting_deadline_seconds"] = *req.StartingDeadlineSeconds
+	}
+
+	if req.DeleteAfterSeconds != nil {
+		updates["delete_after_seconds"] = *req.DeleteAfterSeconds
+	}
+
+	// Setting run_at turns the job into a one-time job, a new schedule turns
+	// it back into a scheduled one.
+	if req.RunAt != nil {
+		runAt := req.RunAt.UTC()
+		updates["run_at"] = runAt
+		updates["next_run"] = runAt
+		updates["recurring"] = false
+		updates["status"] = models.StatusPending
+		updates["retry"] = 0
+		shouldRecalculateNextRun = true
+	}
+
+	// The schedule is evaluated with whatever the request leaves in place.
+	effective := existingJob
 	if req.Schedule != "" {
-		// Get timezone (use existing or new)
-		timezone := existingJob.Timezone
-		if req.Timezone != "" {
-			timezone = req.Timezone
+		effective.Schedule = req.Schedule
+		effective.RunAt = nil
+	}
+	if req.RunAt != nil {
+		effective.RunAt = req.RunAt
+	}
+	if req.Timezone != "" {
+		effective.Timezone = req.Timezone
+	}
+	if req.ScheduleKind != "" {
+		effective.ScheduleKind = req.ScheduleKind
+		updates["schedule_kind"] = req.ScheduleKind
+		shouldRecalculateNextRun = true
+	}
+	if req.JitterSeconds != nil {
+		effective.JitterSeconds = *req.JitterSeconds
+		updates["jitter_seconds"] = *req.JitterSeconds
+		shouldRecalculateNextRun = true
+	}
+
+	// A nil uuid detaches the calendar.
+	if req.CalendarID != nil {
+		if *req.CalendarID == uuid.Nil {
+			effective.CalendarID = nil
+			updates["calendar_id"] = nil
+		} else {
+			if err := validateCalendarOwner(*req.CalendarID, userId); err != nil {
+				c.JSON(400, gin.H{
+					"error": "validation failed: " + err.Error(),
+				})
+				return
+			}
+			effective.CalendarID = req.CalendarID
+			updates["calendar_id"] = *req.CalendarID
 		}
+		shouldRecalculateNextRun = true
+	}
+
+	if err := scheduler.ValidateJitter(&effective); err != nil {
+		c.JSON(400, gin.H{
+			"error": "validation failed: " + err.Error(),
+		})
+		return
+	}
 
+	// Handle schedule update
+	if req.Schedule != "" {
 		// Validate schedule format with timezone
-		nextRun, err := scheduler.GetNextRun(req.Schedule, timezone)
+		nextRun, err := scheduler.NextScheduledRun(&effective, time.Now())
 		if err != nil {
 			c.JSON(400, gin.H{
 				"error": "invalid schedule format: " + err.Error(),
@@ -167,6 +395,10 @@ func UpdateJob(c *gin.Context) {
 
 		updates["schedule"] = req.Schedule
 		updates["next_run"] = nextRun
+		updates["run_at"] = nil
+		if existingJob.RunAt != nil && req.Recurring == nil {
+			updates["recurring"] = true
+		}
 		shouldRecalculateNextRun = true
 
 		// Reset job status when schedule changes
@@ -180,10 +412,11 @@ func UpdateJob(c *gin.Context) {
 		shouldRecalculateNextRun = true
 	}
 
-	// Recalculate next_run if timezone changed but schedule didn't
-	if shouldRecalculateNextRun && req.Schedule == "" {
-		// Use existing schedule with new timezone
-		nextRun, err := scheduler.GetNextRun(existingJob.Schedule, req.Timezone)
+	// Recalculate next_run if timezone changed but schedule didn't, a run_at
+	// job keeps its time.
+	if shouldRecalculateNextRun && req.Schedule == "" && req.RunAt == nil && existingJob.RunAt == nil {
+		// Use existing schedule with new timezone or kind
+		nextRun, err := scheduler.NextScheduledRun(&effective, time.Now())
 		if err != nil {
 			c.JSON(400, gin.H{
 				"error": "failed to recalculate next run: " + err.Error(),
@@ -204,6 +437,9 @@ func UpdateJob(c *gin.Context) {
 		})
 		return
 	}
+	if shouldRecalculateNextRun {
+		scheduler.Wake(c.Request.Context())
+	}
 
 	// Fetch updated job for response
 	var updatedJob models.Job
@@ -295,6 +531,9 @@ func DeleteJob(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "job not found or not owned by user."})
 		return
 	}
+	if jobID, err := uuid.Parse(id); err == nil {
+		worker.ForgetJobToken(jobID.String())
+	}
 	c.JSON(200, gin.H{
 		"message": "successfully deleted",
 		"id":      id,
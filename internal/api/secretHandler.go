@@ -0,0 +1,193 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/middleware"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/secrets"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func CreateSecret(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+
+	var req models.CreateSecretRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid JSON",
+		})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "validation failed: " + err.Error(),
+		})
+		return
+	}
+	if !secrets.ValidName(req.Name) {
+		c.JSON(400, gin.H{
+			"error": "validation failed: name may only contain letters, digits, '.', '_' and '-'",
+		})
+		return
+	}
+
+	var count int64
+	if err := db.DB.Model(&models.Secret{}).Where("user_id = ? AND name = ?", userId, req.Name).Count(&count).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to create secret: " + err.Error(),
+		})
+		return
+	}
+	if count > 0 {
+		c.JSON(409, gin.H{
+			"error": "secret already exists, rotate it instead",
+		})
+		return
+	}
+
+	ciphertext, nonce, err := secrets.Encrypt(userId, req.Name, req.Value)
+	if err != nil {
+		encryptFailed(c, err)
+		return
+	}
+	secret := models.Secret{
+		UserID:     userId,
+		Name:       req.Name,
+		Ciphertext: ciphertext,
+		Nonce:      nonce,
+	}
+	if err := db.DB.Create(&secret).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to create secret: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "secret created",
+		"secret":  secret,
+	})
+}
+
+func GetAllSecrets(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var list []models.Secret
+	if err := db.DB.Where("user_id = ?", userId).Order("name").Find(&list).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch secrets: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"secrets": list,
+	})
+}
+
+func RotateSecret(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	name := c.Param("name")
+
+	var req models.RotateSecretRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid JSON",
+		})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	var secret models.Secret
+	if err := db.DB.Where("user_id = ? AND name = ?", userId, name).First(&secret).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": "secret not found"})
+		} else {
+			c.JSON(500, gin.H{"error": "failed to fetch secret: " + err.Error()})
+		}
+		return
+	}
+
+	ciphertext, nonce, err := secrets.Encrypt(userId, name, req.Value)
+	if err != nil {
+		encryptFailed(c, err)
+		return
+	}
+	secret.Ciphertext = ciphertext
+	secret.Nonce = nonce
+	if err := db.DB.Save(&secret).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to rotate secret: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"message": "secret rotated",
+		"secret":  secret,
+	})
+}
+
+func DeleteSecret(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	name := c.Param("name")
+	tx := db.DB.Delete(&models.Secret{}, "user_id = ? AND name = ?", userId, name)
+	if tx.Error != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to delete secret: " + tx.Error.Error(),
+		})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(404, gin.H{"error": "secret not found"})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": "successfully deleted",
+		"name":    name,
+	})
+}
+
+// encryptFailed answers a request whose secret could not be encrypted. A
+// missing or bad master key is the operator's to fix, not a server fault.
+func encryptFailed(c *gin.Context, err error) {
+	if errors.Is(err, secrets.ErrNotConfigured) {
+		c.JSON(503, gin.H{
+			"error": "secrets not configured",
+		})
+		return
+	}
+	c.JSON(500, gin.H{
+		"error": "failed to encrypt secret: " + err.Error(),
+	})
+}
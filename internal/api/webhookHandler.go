@@ -0,0 +1,164 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/middleware"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/secrets"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func CreateWebhook(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid JSON",
+		})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	if req.JobID != nil {
+		var count int64
+		if err := db.DB.Model(&models.Job{}).Where("id = ? AND user_id = ?", *req.JobID, userId).Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{
+				"error": "failed to create webhook: " + err.Error(),
+			})
+			return
+		}
+		if count == 0 {
+			c.JSON(404, gin.H{"error": "job not found or not owned by user."})
+			return
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			c.JSON(500, gin.H{
+				"error": "failed to generate secret: " + err.Error(),
+			})
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	webhook := models.Webhook{
+		ID:      uuid.New(),
+		UserID:  userId,
+		JobID:   req.JobID,
+		URL:     req.URL,
+		Events:  req.Events,
+		Enabled: true,
+	}
+	webhook.SecretCiphertext, webhook.SecretNonce, err = secrets.EncryptWebhookSecret(webhook.ID, secret)
+	if err != nil {
+		encryptFailed(c, err)
+		return
+	}
+	if err := db.DB.Create(&webhook).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to create webhook: " + err.Error(),
+		})
+		return
+	}
+
+	// The signing secret is only ever returned here.
+	c.JSON(200, gin.H{
+		"message": "webhook created",
+		"webhook": webhook,
+		"secret":  secret,
+	})
+}
+
+func GetAllWebhooks(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var list []models.Webhook
+	if err := db.DB.Where("user_id = ?", userId).Order("created_at").Find(&list).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch webhooks: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"webhooks": list,
+	})
+}
+
+func DeleteWebhook(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	id := c.Param("id")
+	tx := db.DB.Delete(&models.Webhook{}, "id = ? AND user_id = ?", id, userId)
+	if tx.Error != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to delete webhook: " + tx.Error.Error(),
+		})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(404, gin.H{"error": "webhook not found or not owned by user."})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": "successfully deleted",
+		"id":      id,
+	})
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var webhook models.Webhook
+	if err := db.DB.First(&webhook, "id = ? AND user_id = ?", c.Param("id"), userId).Error; err != nil {
+		c.JSON(404, gin.H{
+			"error": "webhook not found",
+		})
+		return
+	}
+	var deliveries []models.WebhookDelivery
+	err = db.DB.Where("webhook_id = ?", webhook.ID).Order("created_at DESC").Limit(100).Find(&deliveries).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch webhook deliveries: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"deliveries": deliveries,
+	})
+}
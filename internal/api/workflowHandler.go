@@ -0,0 +1,204 @@
+package api
+
+import (
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/middleware"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func CreateWorkflow(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+
+	var req models.CreateWorkflowRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid JSON",
+		})
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		c.JSON(400, gin.H{
+			"error": "validation failed: " + err.Error(),
+		})
+		return
+	}
+
+	var nodes []models.WorkflowNode
+	jobIds := make(map[uuid.UUID]bool)
+	for _, n := range req.Nodes {
+		deps := make([]models.WorkflowDependency, len(n.DependsOn))
+		for i, dep := range n.DependsOn {
+			if dep.Condition == "" {
+				dep.Condition = models.ConditionSuccess
+			}
+			deps[i] = dep
+		}
+		nodes = append(nodes, models.WorkflowNode{
+			Key:       n.Key,
+			JobID:     n.JobID,
+			DependsOn: deps,
+		})
+		jobIds[n.JobID] = true
+	}
+	if err := scheduler.ValidateWorkflow(nodes); err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid workflow: " + err.Error(),
+		})
+		return
+	}
+
+	// Every node must point at a job owned by the caller.
+	ids := make([]uuid.UUID, 0, len(jobIds))
+	for id := range jobIds {
+		ids = append(ids, id)
+	}
+	var owned int64
+	if err := db.DB.Model(&models.Job{}).Where("id IN ? AND user_id = ?", ids, userId).Count(&owned).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to create workflow: " + err.Error(),
+		})
+		return
+	}
+	if int(owned) != len(ids) {
+		c.JSON(400, gin.H{
+			"error": "invalid workflow: unknown job in nodes",
+		})
+		return
+	}
+
+	nextRun, err := scheduler.GetNextRun(req.Schedule, req.Timezone)
+	if err != nil {
+		c.JSON(400, gin.H{
+			"error": "invalid schedule format: " + err.Error(),
+		})
+		return
+	}
+
+	workflow := models.Workflow{
+		Name:     req.Name,
+		Schedule: req.Schedule,
+		Timezone: req.Timezone,
+		Enabled:  *req.Enabled,
+		NextRun:  nextRun,
+		UserID:   userId,
+		Nodes:    nodes,
+	}
+	if err := db.DB.Create(&workflow).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to create workflow: " + err.Error(),
+		})
+		return
+	}
+	scheduler.Wake(c.Request.Context())
+
+	c.JSON(200, gin.H{
+		"message": "Workflow recived",
+		"id":      workflow.ID,
+	})
+}
+
+func GetAllWorkflows(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var workflows []models.Workflow
+	if err := db.DB.Preload("Nodes").Where("user_id = ?", userId).Find(&workflows).Error; err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch workflows: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"workflows": workflows,
+	})
+}
+
+func GetWorkflow(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var workflow models.Workflow
+	if err := db.DB.Preload("Nodes").First(&workflow, "id = ? AND user_id = ?", c.Param("id"), userId).Error; err != nil {
+		c.JSON(404, gin.H{
+			"error": "workflow not found",
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"workflow": workflow,
+	})
+}
+
+func DeleteWorkflow(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	id := c.Param("id")
+	tx := db.DB.Delete(&models.Workflow{}, "id = ? AND user_id = ?", id, userId)
+	if tx.Error != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to delete workflow: " + tx.Error.Error(),
+		})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(404, gin.H{"error": "workflow not found or not owned by user."})
+		return
+	}
+	c.JSON(200, gin.H{
+		"message": "successfully deleted",
+		"id":      id,
+	})
+}
+
+func GetWorkflowRuns(c *gin.Context) {
+	userId, err := middleware.ParseUserID(c)
+	if err != nil {
+		c.JSON(401, gin.H{
+			"error": "error parsing userId: " + err.Error(),
+		})
+		return
+	}
+	var workflow models.Workflow
+	if err := db.DB.First(&workflow, "id = ? AND user_id = ?", c.Param("id"), userId).Error; err != nil {
+		c.JSON(404, gin.H{
+			"error": "workflow not found",
+		})
+		return
+	}
+	var runs []models.WorkflowRun
+	err = db.DB.Preload("Nodes", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("node_key")
+	}).Where("workflow_id = ?", workflow.ID).Order("started_at DESC").Limit(50).Find(&runs).Error
+	if err != nil {
+		c.JSON(500, gin.H{
+			"error": "failed to fetch workflow runs: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"runs": runs,
+	})
+}
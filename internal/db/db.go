@@ -1,16 +1,22 @@
 package db
 
 import (
+	"context"
 	"log"
 	"os"
 
 	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// JobDB is the datasource sql jobs run against. It is nil when
+// SQL_JOB_DB_URL is not set.
+var JobDB *pgxpool.Pool
+
 func InitDB() {
 	dsn := os.Getenv("DB_URL")
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
@@ -21,7 +27,36 @@ func InitDB() {
 	db.AutoMigrate(&models.Job{})
 	db.AutoMigrate(&models.Logs{})
 	db.AutoMigrate(&models.UserIdentity{})
+	db.AutoMigrate(&models.Secret{})
+	db.AutoMigrate(&models.Workflow{})
+	db.AutoMigrate(&models.WorkflowNode{})
+	db.AutoMigrate(&models.WorkflowRun{})
+	db.AutoMigrate(&models.WorkflowNodeRun{})
+	db.AutoMigrate(&models.Webhook{})
+	db.AutoMigrate(&models.WebhookDelivery{})
+	db.AutoMigrate(&models.Calendar{})
 
 	DB = db
 	log.Println("successfully connected to database!")
 }
+
+// InitJobDB connects to the postgres datasource used by sql jobs. It is kept
+// separate from DB so scheduled queries never touch gocrony's own tables.
+func InitJobDB() error {
+	dsn := os.Getenv("SQL_JOB_DB_URL")
+	if dsn == "" {
+		log.Println("SQL_JOB_DB_URL not set, sql jobs are disabled")
+		return nil
+	}
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		return err
+	}
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		return err
+	}
+	JobDB = pool
+	log.Println("successfully connected to sql job datasource!")
+	return nil
+}
@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"errors"
+	"slices"
 	"strings"
 
+	"github.com/akhilbisht798/gocrony/config"
 	"github.com/akhilbisht798/gocrony/internal/auth"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -51,3 +53,17 @@ func ParseUserID(c *gin.Context) (uuid.UUID, error) {
 
 	return id, nil
 }
+
+// AdminMiddleWare only lets through users whose email is listed in
+// ADMIN_EMAILS. It must run after AuthMiddleWare.
+func AdminMiddleWare() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, _ := c.Get("email")
+		emailStr, _ := email.(string)
+		if emailStr == "" || !slices.Contains(config.GetEnvList("ADMIN_EMAILS"), emailStr) {
+			c.AbortWithStatusJSON(403, gin.H{"error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
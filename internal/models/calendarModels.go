@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Calendar suppresses the runs of the jobs referencing it that fall on an
+// excluded date, inside an excluded date range or inside a weekly window.
+// Dates and times are read in the calendar's own timezone.
+type Calendar struct {
+	ID        uuid.UUID        `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID        `gorm:"type:uuid;index" json:"user_id"`
+	Name      string           `json:"name"`
+	Timezone  string           `json:"timezone"`
+	Dates     []CalendarDate   `gorm:"serializer:json" json:"dates"`
+	Ranges    []CalendarRange  `gorm:"serializer:json" json:"ranges"`
+	Windows   []CalendarWindow `gorm:"serializer:json" json:"windows"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	User      User             `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// CalendarDate excludes a whole day, e.g. a bank holiday.
+type CalendarDate struct {
+	Date string `json:"date" validate:"required,datetime=2006-01-02"`
+	Name string `json:"name,omitempty"`
+}
+
+// CalendarRange excludes every day from Start to End inclusive.
+type CalendarRange struct {
+	Start string `json:"start" validate:"required,datetime=2006-01-02"`
+	End   string `json:"end" validate:"required,datetime=2006-01-02"`
+	Name  string `json:"name,omitempty"`
+}
+
+// CalendarWindow excludes Start to End on each of Days. An End at or before
+// Start runs past midnight into the following day.
+type CalendarWindow struct {
+	Days  []string `json:"days" validate:"required,min=1,dive,oneof=sun mon tue wed thu fri sat"`
+	Start string   `json:"start" validate:"required,datetime=15:04"`
+	End   string   `json:"end" validate:"required,datetime=15:04"`
+	Name  string   `json:"name,omitempty"`
+}
+
+func (c *Calendar) BeforeCreate(tx *gorm.DB) (err error) {
+	c.ID = uuid.New()
+	return
+}
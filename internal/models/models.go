@@ -13,7 +13,7 @@ type StatusType string
 
 const (
 	JobTypeHTTP JobType = "http"
-	// JobTypeShell JobType = "shell"
+	JobTypeShell JobType = "shell"
 	JobTypeSQL   JobType = "sql"
 	JobTypeQueue JobType = "queue"
 )
@@ -23,16 +23,101 @@ const (
 	StatusPending StatusType = "pending"
 	StatusFailed StatusType = "failed"
 	StatusAborted StatusType = "aborted"
+	StatusCompleted StatusType = "completed" // one-time job that has run
 )
 
+// Statuses of Logs entries for runs that never reached an executor or were
+// cut short by a newer run, and for jobs the reaper reset.
+const (
+	LogStatusSkipped   = "skipped"
+	LogStatusReplaced  = "replaced"
+	LogStatusRecovered = "recovered"
+)
+
+// ConcurrencyPolicy decides what happens when a run starts while a previous
+// run of the same job is still executing.
+type ConcurrencyPolicy string
+
+const (
+	ConcurrencyAllow   ConcurrencyPolicy = "allow"
+	ConcurrencyForbid  ConcurrencyPolicy = "forbid"  // skip the new run
+	ConcurrencyReplace ConcurrencyPolicy = "replace" // cancel the running one
+)
+
+// ScheduleKind says how Schedule is read.
+type ScheduleKind string
+
+const (
+	ScheduleCron       ScheduleKind = "cron"        // cron expression or descriptor such as @daily
+	ScheduleInterval   ScheduleKind = "interval"    // "@every 90m", counted from the job's creation
+	ScheduleFixedDelay ScheduleKind = "fixed_delay" // "@every 5m", counted from the end of the previous run
+)
+
+// MisfirePolicy decides what happens to occurrences that were due while the
+// scheduler was not running.
+type MisfirePolicy string
+
+const (
+	MisfireRunOnce MisfirePolicy = "run_once" // one run covers every missed occurrence
+	MisfireRunAll  MisfirePolicy = "run_all"  // run each missed occurrence, up to MaxCatchUp
+	MisfireSkip    MisfirePolicy = "skip"     // drop missed occurrences
+)
+
+const DEFAULT_MAX_CATCH_UP = 10
+
+type BackoffStrategy string
+
+const (
+	BackoffFixed       BackoffStrategy = "fixed"
+	BackoffLinear      BackoffStrategy = "linear"
+	BackoffExponential BackoffStrategy = "exponential"
+)
+
+const (
+	DEFAULT_MAX_ATTEMPTS        = 3
+	DEFAULT_RETRY_INITIAL_DELAY = 60
+	DEFAULT_RETRY_MAX_DELAY     = 3600
+	MAX_RETRY_DELAY             = 86400 // keep in sync with the validate tags below
+	MAX_RETRY_JITTER            = 3600
+)
+
+// RetryPolicy controls how a failed run is retried. MaxAttempts counts the
+// first run too, so 1 means never retry. Zero values fall back to defaults.
+type RetryPolicy struct {
+	MaxAttempts         int             `json:"max_attempts" validate:"omitempty,min=1,max=100"`
+	Backoff             BackoffStrategy `json:"backoff" validate:"omitempty,oneof=fixed linear exponential"`
+	InitialDelaySeconds int             `json:"initial_delay_seconds" validate:"min=0,max=86400"`
+	MaxDelaySeconds     int             `json:"max_delay_seconds" validate:"min=0,max=86400"`
+	JitterSeconds       int             `json:"jitter_seconds" validate:"min=0,max=3600"`
+}
+
+func (p RetryPolicy) WithDefaults() RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = DEFAULT_MAX_ATTEMPTS
+	}
+	if p.Backoff == "" {
+		p.Backoff = BackoffExponential
+	}
+	if p.InitialDelaySeconds == 0 {
+		p.InitialDelaySeconds = DEFAULT_RETRY_INITIAL_DELAY
+	}
+	if p.MaxDelaySeconds == 0 {
+		p.MaxDelaySeconds = DEFAULT_RETRY_MAX_DELAY
+	}
+	return p
+}
+
 type Job struct {
 	ID        uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`
 	LastRun   *time.Time      `json:"last_run,omitempty"`
 	NextRun   *time.Time      `json:"next_run,omitempty"`
+	QueuedAt  *time.Time      `json:"queued_at,omitempty"`
 	CreatedAt time.Time       `json:"created_at"`
 	Schedule  string          `json:"schedule" gorm:"default:'* * * * *'"`
+	ScheduleKind ScheduleKind `json:"schedule_kind" gorm:"default:'cron'"`
 	Name      string          `json:"name"`
 	Payload   json.RawMessage `json:"payload"` // one-time or recurring
+	Templated bool            `json:"templated"` // payload strings get the full template helpers, otherwise only secret refs
 	Type      JobType         `json:"type"`
 	Recurring bool			  `json:"recurring"`
 	Enabled   bool 			  `json:"enabled"`
@@ -40,12 +125,31 @@ type Job struct {
 	Timezone  string           `json:"timezone"`
 	UserID    uuid.UUID       `gorm:"type:uuid;index" json:"user_id"`
 	Retry		int 		   `json:"retry"`
+	RetryPolicy RetryPolicy   `gorm:"embedded;embeddedPrefix:retry_" json:"retry_policy"`
+	TimeoutSeconds int        `json:"timeout_seconds"` // 0 uses the server default
+	AlertEmails []string      `gorm:"serializer:json" json:"alert_emails,omitempty"` // alerted along with the owner
+	AlertAfterFailures int    `json:"alert_after_failures"` // 0 only alerts on abort
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy" gorm:"default:'allow'"`
+	MisfirePolicy MisfirePolicy `json:"misfire_policy" gorm:"default:'run_once'"`
+	MaxCatchUp int             `json:"max_catch_up"` // run_all only, 0 uses DEFAULT_MAX_CATCH_UP
+	StartingDeadlineSeconds int `json:"starting_deadline_seconds"` // 0 never skips a late run
+	RunAt     *time.Time      `json:"run_at,omitempty"` // one-time jobs run at this time instead of following Schedule
+	DeleteAfterSeconds int    `json:"delete_after_seconds"` // completed jobs are deleted this long after their run, 0 keeps them
+	CalendarID *uuid.UUID     `gorm:"type:uuid;index" json:"calendar_id,omitempty"` // runs the calendar excludes are skipped
+	JitterSeconds int         `json:"jitter_seconds"` // each run is delayed by a random part of this window
 	User      User            `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 	Logs      []Logs          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
+// OneTime reports whether the job completes after its first successful run
+// instead of being rescheduled.
+func (job *Job) OneTime() bool {
+	return job.RunAt != nil || !job.Recurring
+}
+
 type Logs struct {
 	ID       uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	RunID    uuid.UUID `gorm:"type:uuid;index" json:"run_id"`
 	Status   string `json:"status"`
 	StatusCode int 	`json:"status_code"`
 	Response string    `json:"response"`
@@ -55,6 +159,19 @@ type Logs struct {
 	Job      Job       `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
 }
 
+// Secret is a user owned value encrypted with the server master key. Only its
+// name is ever returned by the API.
+type Secret struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_secret_user_name" json:"-"`
+	Name       string    `gorm:"uniqueIndex:idx_secret_user_name" json:"name"`
+	Ciphertext []byte    `json:"-"`
+	Nonce      []byte    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	User       User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
 type User struct {
 	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
 	Email     string    `gorm:"uniqueIndex"`
@@ -76,8 +193,12 @@ type UserIdentity struct {
 
 }
 
+// BeforeCreate keeps an id set by the caller, hashed schedules need it to
+// compute the first run before the job is saved.
 func (job *Job) BeforeCreate(tx *gorm.DB) (err error) {
-	job.ID = uuid.New()
+	if job.ID == uuid.Nil {
+		job.ID = uuid.New()
+	}
 	return
 }
 
@@ -86,6 +207,11 @@ func (log *Logs) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (secret *Secret) BeforeCreate(tx *gorm.DB) (err error) {
+	secret.ID = uuid.New()
+	return
+}
+
 func (user *User) BeforeCreate(tx *gorm.DB) (err error) {
 	user.ID = uuid.New()
 	return
@@ -2,26 +2,57 @@ package models
 
 import (
 	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type CreateJobRequest struct {
 	Name     string          `json:"name" validate:"required"`
 	Payload  json.RawMessage `json:"payload" validate:"required"`
-	Schedule string          `json:"schedule" validate:"required"`
-	Type     JobType         `json:"type" validate:"required,oneof=http sql queue"`
-	Recurring *bool 		`json:"recurring" validate:"required"`
+	Templated bool           `json:"templated"`
+	Schedule string          `json:"schedule" validate:"required_without=RunAt,excluded_with=RunAt"`
+	RunAt    *time.Time      `json:"run_at,omitempty" validate:"required_without=Schedule"`
+	ScheduleKind ScheduleKind `json:"schedule_kind,omitempty" validate:"omitempty,oneof=cron interval fixed_delay"`
+	Type     JobType         `json:"type" validate:"required,oneof=http sql queue shell"`
+	Recurring *bool 		`json:"recurring" validate:"required_without=RunAt"`
 	Enabled   *bool  		`json:"enabled" validate:"required"`
 	Timezone string          `json:"timezone" validate:"required"`
+	TimeoutSeconds *int      `json:"timeout_seconds,omitempty" validate:"omitempty,min=1"`
+	RetryPolicy *RetryPolicy `json:"retry_policy,omitempty"`
+	AlertEmails []string     `json:"alert_emails,omitempty" validate:"omitempty,max=20,dive,email"`
+	AlertAfterFailures *int  `json:"alert_after_failures,omitempty" validate:"omitempty,min=0"`
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy,omitempty" validate:"omitempty,oneof=allow forbid replace"`
+	MisfirePolicy MisfirePolicy `json:"misfire_policy,omitempty" validate:"omitempty,oneof=run_once run_all skip"`
+	MaxCatchUp *int             `json:"max_catch_up,omitempty" validate:"omitempty,min=0,max=1000"`
+	StartingDeadlineSeconds *int `json:"starting_deadline_seconds,omitempty" validate:"omitempty,min=0"`
+	DeleteAfterSeconds *int  `json:"delete_after_seconds,omitempty" validate:"omitempty,min=0"`
+	CalendarID *uuid.UUID    `json:"calendar_id,omitempty"`
+	JitterSeconds *int       `json:"jitter_seconds,omitempty" validate:"omitempty,min=0,max=3600"`
 }
 
 type UpdateJobRequest struct {
 	Name     string          `json:"name,omitempty"`
 	Payload  json.RawMessage `json:"payload,omitempty"`
-	Schedule string          `json:"schedule,omitempty"`
-	Type     JobType         `json:"type,omitempty" validate:"omitempty,oneof=http sql queue"`
+	Templated *bool          `json:"templated,omitempty"`
+	Schedule string          `json:"schedule,omitempty" validate:"excluded_with=RunAt"`
+	RunAt    *time.Time      `json:"run_at,omitempty"`
+	ScheduleKind ScheduleKind `json:"schedule_kind,omitempty" validate:"omitempty,oneof=cron interval fixed_delay"`
+	Type     JobType         `json:"type,omitempty" validate:"omitempty,oneof=http sql queue shell"`
 	Recurring *bool 		`json:"recurring,omitempty"`
 	Enabled   *bool  		`json:"enabled,omitempty"`
 	Timezone string          `json:"timezone,omitempty"`
+	TimeoutSeconds *int      `json:"timeout_seconds,omitempty" validate:"omitempty,min=0"`
+	RetryPolicy *RetryPolicy `json:"retry_policy,omitempty"`
+	AlertEmails []string     `json:"alert_emails,omitempty" validate:"omitempty,max=20,dive,email"`
+	AlertAfterFailures *int  `json:"alert_after_failures,omitempty" validate:"omitempty,min=0"`
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrency_policy,omitempty" validate:"omitempty,oneof=allow forbid replace"`
+	MisfirePolicy MisfirePolicy `json:"misfire_policy,omitempty" validate:"omitempty,oneof=run_once run_all skip"`
+	MaxCatchUp *int             `json:"max_catch_up,omitempty" validate:"omitempty,min=0,max=1000"`
+	StartingDeadlineSeconds *int `json:"starting_deadline_seconds,omitempty" validate:"omitempty,min=0"`
+	DeleteAfterSeconds *int  `json:"delete_after_seconds,omitempty" validate:"omitempty,min=0"`
+	CalendarID *uuid.UUID    `json:"calendar_id,omitempty"`
+	JitterSeconds *int       `json:"jitter_seconds,omitempty" validate:"omitempty,min=0,max=3600"`
 }
 
 type UserSignUpRequest struct {
@@ -35,3 +66,41 @@ type UserLoginRequest struct {
 	Email     string `json:"email" validate:"required,email"`
 	Password  string `json:"password" validate:"required,min=6"`
 }
+
+type CreateSecretRequest struct {
+	Name  string `json:"name" validate:"required"`
+	Value string `json:"value" validate:"required"`
+}
+
+type RotateSecretRequest struct {
+	Value string `json:"value" validate:"required"`
+}
+
+type CreateWorkflowRequest struct {
+	Name     string                `json:"name" validate:"required"`
+	Schedule string                `json:"schedule" validate:"required"`
+	Timezone string                `json:"timezone" validate:"required"`
+	Enabled  *bool                 `json:"enabled" validate:"required"`
+	Nodes    []WorkflowNodeRequest `json:"nodes" validate:"required,min=1,dive"`
+}
+
+type WorkflowNodeRequest struct {
+	Key       string               `json:"key" validate:"required"`
+	JobID     uuid.UUID            `json:"job_id" validate:"required"`
+	DependsOn []WorkflowDependency `json:"depends_on,omitempty" validate:"dive"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string         `json:"url" validate:"required,url"`
+	Secret string         `json:"secret,omitempty"`
+	Events []WebhookEvent `json:"events" validate:"required,min=1,dive,oneof=success failure abort recovery"`
+	JobID  *uuid.UUID     `json:"job_id,omitempty"`
+}
+
+type CalendarRequest struct {
+	Name     string           `json:"name" validate:"required"`
+	Timezone string           `json:"timezone" validate:"required"`
+	Dates    []CalendarDate   `json:"dates,omitempty" validate:"dive"`
+	Ranges   []CalendarRange  `json:"ranges,omitempty" validate:"dive"`
+	Windows  []CalendarWindow `json:"windows,omitempty" validate:"dive"`
+}
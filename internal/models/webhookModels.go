@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WebhookEvent string
+
+const (
+	EventSuccess  WebhookEvent = "success"
+	EventFailure  WebhookEvent = "failure"
+	EventAbort    WebhookEvent = "abort"
+	EventRecovery WebhookEvent = "recovery"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryQueued    DeliveryStatus = "queued"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Webhook receives a signed JSON document whenever a run of one of the
+// owner's jobs ends with one of Events. A nil JobID subscribes to every job
+// of the user.
+type Webhook struct {
+	ID               uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID           uuid.UUID      `gorm:"type:uuid;index" json:"user_id"`
+	JobID            *uuid.UUID     `gorm:"type:uuid;index" json:"job_id,omitempty"`
+	URL              string         `json:"url"`
+	SecretCiphertext []byte         `json:"-"` // signing secret, sealed like Secret values
+	SecretNonce      []byte         `json:"-"`
+	Events           []WebhookEvent `gorm:"serializer:json" json:"events"`
+	Enabled          bool           `json:"enabled"`
+	CreatedAt        time.Time      `json:"created_at"`
+	User             User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+func (w *Webhook) Subscribes(event WebhookEvent) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`
+	WebhookID     uuid.UUID       `gorm:"type:uuid;index" json:"webhook_id"`
+	JobID         uuid.UUID       `gorm:"type:uuid" json:"job_id"`
+	RunID         uuid.UUID       `gorm:"type:uuid" json:"run_id"`
+	Event         WebhookEvent    `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `gorm:"index" json:"status"`
+	Attempts      int             `json:"attempts"`
+	StatusCode    int             `json:"status_code"`
+	Error         string          `json:"error,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Webhook       Webhook         `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+// BeforeCreate keeps an id set by the caller, the secret is sealed to it
+// before the webhook is saved.
+func (w *Webhook) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) (err error) {
+	d.ID = uuid.New()
+	return
+}
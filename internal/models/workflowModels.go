@@ -0,0 +1,113 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WorkflowCondition string
+
+const (
+	ConditionSuccess WorkflowCondition = "success"
+	ConditionFailure WorkflowCondition = "failure"
+	ConditionAlways  WorkflowCondition = "always"
+)
+
+type WorkflowRunStatus string
+
+const (
+	WorkflowRunning   WorkflowRunStatus = "running"
+	WorkflowSucceeded WorkflowRunStatus = "succeeded"
+	WorkflowFailed    WorkflowRunStatus = "failed"
+)
+
+type NodeRunStatus string
+
+const (
+	NodeWaiting   NodeRunStatus = "waiting"
+	NodeQueued    NodeRunStatus = "queued"
+	NodeRunning   NodeRunStatus = "running"
+	NodeSucceeded NodeRunStatus = "succeeded"
+	NodeFailed    NodeRunStatus = "failed"
+	NodeSkipped   NodeRunStatus = "skipped"
+)
+
+// Workflow runs a DAG of existing jobs on its own schedule. Only nodes without
+// dependencies are started by the scheduler, the rest run as their upstream
+// nodes finish.
+type Workflow struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	Name      string         `json:"name"`
+	Schedule  string         `json:"schedule"`
+	Timezone  string         `json:"timezone"`
+	Enabled   bool           `json:"enabled"`
+	NextRun   *time.Time     `json:"next_run,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UserID    uuid.UUID      `gorm:"type:uuid;index" json:"user_id"`
+	User      User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Nodes     []WorkflowNode `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"nodes,omitempty"`
+}
+
+// WorkflowDependency makes a node wait for Node, identified by its key, and
+// only run if Node finished in a way matching Condition.
+type WorkflowDependency struct {
+	Node      string            `json:"node" validate:"required"`
+	Condition WorkflowCondition `json:"condition" validate:"omitempty,oneof=success failure always"`
+}
+
+type WorkflowNode struct {
+	ID         uuid.UUID            `gorm:"type:uuid;primaryKey" json:"id"`
+	WorkflowID uuid.UUID            `gorm:"type:uuid;index" json:"workflow_id"`
+	Key        string               `json:"key"`
+	JobID      uuid.UUID            `gorm:"type:uuid;index" json:"job_id"`
+	DependsOn  []WorkflowDependency `gorm:"serializer:json" json:"depends_on,omitempty"`
+	Job        Job                  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+}
+
+type WorkflowRun struct {
+	ID         uuid.UUID         `gorm:"type:uuid;primaryKey" json:"id"`
+	WorkflowID uuid.UUID         `gorm:"type:uuid;index" json:"workflow_id"`
+	Status     WorkflowRunStatus `json:"status"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Workflow   Workflow          `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-"`
+	Nodes      []WorkflowNodeRun `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"nodes,omitempty"`
+}
+
+// WorkflowNodeRun tracks one node within a workflow run. RunID matches the
+// run_id of the logs written by the execution.
+type WorkflowNodeRun struct {
+	ID            uuid.UUID     `gorm:"type:uuid;primaryKey" json:"id"`
+	WorkflowRunID uuid.UUID     `gorm:"type:uuid;index" json:"workflow_run_id"`
+	NodeID        uuid.UUID     `gorm:"type:uuid" json:"node_id"`
+	NodeKey       string        `json:"node_key"`
+	JobID         uuid.UUID     `gorm:"type:uuid" json:"job_id"`
+	Status        NodeRunStatus `json:"status"`
+	RunID         *uuid.UUID    `gorm:"type:uuid" json:"run_id,omitempty"`
+	StartedAt     *time.Time    `json:"started_at,omitempty"`
+	FinishedAt    *time.Time    `json:"finished_at,omitempty"`
+}
+
+func (workflow *Workflow) BeforeCreate(tx *gorm.DB) (err error) {
+	workflow.ID = uuid.New()
+	return
+}
+
+func (node *WorkflowNode) BeforeCreate(tx *gorm.DB) (err error) {
+	if node.ID == uuid.Nil {
+		node.ID = uuid.New()
+	}
+	return
+}
+
+func (run *WorkflowRun) BeforeCreate(tx *gorm.DB) (err error) {
+	run.ID = uuid.New()
+	return
+}
+
+func (nodeRun *WorkflowNodeRun) BeforeCreate(tx *gorm.DB) (err error) {
+	nodeRun.ID = uuid.New()
+	return
+}
@@ -0,0 +1,237 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/config"
+	"github.com/akhilbisht798/gocrony/internal/cache"
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_ALERT_RATE_LIMIT = 15 * time.Minute
+	SMTP_TIMEOUT             = 15 * time.Second
+	// ALERT_PREFIX keys mark an alert as sent for the rate limit window.
+	ALERT_PREFIX = "jobs:alerts:"
+)
+
+const (
+	SMTPTLSNone     = "none"
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "tls"
+)
+
+type smtpConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	TLS      string
+}
+
+// smtpFromEnv reads the SMTP_* variables. Email alerts are disabled while
+// SMTP_HOST is unset.
+func smtpFromEnv() (smtpConfig, bool) {
+	cfg := smtpConfig{
+		Host:     config.GetEnv("SMTP_HOST", ""),
+		Port:     config.GetEnv("SMTP_PORT", "587"),
+		Username: config.GetEnv("SMTP_USERNAME", ""),
+		Password: config.GetEnv("SMTP_PASSWORD", ""),
+		From:     config.GetEnv("SMTP_FROM", "gocrony@localhost"),
+		TLS:      strings.ToLower(config.GetEnv("SMTP_TLS", SMTPTLSStartTLS)),
+	}
+	return cfg, cfg.Host != ""
+}
+
+// alertRateLimit is how long an alert of one kind is suppressed for a job
+// after it was sent, it is read from ALERT_RATE_LIMIT_SECONDS.
+func alertRateLimit() time.Duration {
+	seconds := config.GetEnvInt("ALERT_RATE_LIMIT_SECONDS", 0)
+	if seconds <= 0 {
+		return DEFAULT_ALERT_RATE_LIMIT
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// EmailAlert mails the job owner and the job's alert_emails when a run
+// aborted the job, when the failure count reaches alert_after_failures, or
+// when the job succeeds again after such an alert. previousRetry is the
+// failure count before this run.
+func EmailAlert(ctx context.Context, job *models.Job, event RunEvent, previousRetry int) {
+	cfg, ok := smtpFromEnv()
+	if !ok {
+		return
+	}
+
+	var subject string
+	switch event.Event {
+	case models.EventAbort:
+		subject = fmt.Sprintf("Job %s aborted", job.Name)
+	case models.EventFailure:
+		if job.AlertAfterFailures == 0 || job.Retry != job.AlertAfterFailures {
+			return
+		}
+		subject = fmt.Sprintf("Job %s failed %d times in a row", job.Name, job.Retry)
+	case models.EventRecovery:
+		if job.AlertAfterFailures == 0 || previousRetry < job.AlertAfterFailures {
+			return
+		}
+		subject = fmt.Sprintf("Job %s recovered", job.Name)
+	default:
+		return
+	}
+
+	// Only the first alert of a kind in the window goes out, so a flapping
+	// job sends one mail per window instead of one per run.
+	key := fmt.Sprintf("%s%s:%s", ALERT_PREFIX, job.ID, event.Event)
+	sent, err := cache.Rbd.SetNX(ctx, key, 1, alertRateLimit()).Result()
+	if err != nil {
+		log.Printf("Error rate limiting alert for job %s: %v", job.ID, err)
+		return
+	}
+	if !sent {
+		return
+	}
+
+	recipients := append([]string{}, job.AlertEmails...)
+	var owner models.User
+	if err := db.DB.Select("email").Where("id = ?", job.UserID).First(&owner).Error; err == nil && owner.Email != "" {
+		recipients = append(recipients, owner.Email)
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, SMTP_TIMEOUT)
+	defer cancel()
+	msg := buildMessage(cfg.From, recipients, subject, alertBody(job, event))
+	if err := sendMail(sendCtx, cfg, recipients, msg); err != nil {
+		log.Printf("Error sending %s alert for job %s: %v", event.Event, job.ID, err)
+		// Let the next occurrence try again instead of waiting out the window.
+		cache.Rbd.Del(context.Background(), key)
+	}
+}
+
+func alertBody(job *models.Job, event RunEvent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job:      %s (%s)\n", job.Name, job.ID)
+	fmt.Fprintf(&b, "Type:     %s\n", job.Type)
+	fmt.Fprintf(&b, "Event:    %s\n", event.Event)
+	fmt.Fprintf(&b, "Status:   %s\n", job.Status)
+	fmt.Fprintf(&b, "Failures: %d\n", job.Retry)
+	if event.Run.ID != uuid.Nil {
+		fmt.Fprintf(&b, "Run:      %s (attempt %d)\n", event.Run.ID, event.Run.Attempt)
+		fmt.Fprintf(&b, "Fired at: %s\n", event.Run.FiredAt.Format(time.RFC3339))
+	}
+	if event.Run.Status != "" {
+		fmt.Fprintf(&b, "Result:   %s %d in %dms\n", event.Run.Status, event.Run.StatusCode, event.Run.DurationMs)
+	}
+	if job.NextRun != nil && job.Status != models.StatusAborted {
+		fmt.Fprintf(&b, "Next run: %s\n", job.NextRun.Format(time.RFC3339))
+	}
+	if event.Run.Response != "" {
+		response := event.Run.Response
+		if len(response) > 2*1024 {
+			response = response[:2*1024]
+		}
+		fmt.Fprintf(&b, "\n%s\n", response)
+	}
+	return b.String()
+}
+
+func buildMessage(from string, to []string, subject string, body string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[gocrony] "+stripNewlines(subject)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// sendMail is smtp.SendMail with a context deadline and a choice between
+// plain, STARTTLS and implicit TLS connections.
+func sendMail(ctx context.Context, cfg smtpConfig, to []string, msg []byte) error {
+	addr := net.JoinHostPort(cfg.Host, cfg.Port)
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{}
+	switch cfg.TLS {
+	case SMTPTLSImplicit:
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	case SMTPTLSNone, SMTPTLSStartTLS:
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	default:
+		return fmt.Errorf("unknown SMTP_TLS mode %q", cfg.TLS)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if cfg.TLS == SMTPTLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted
+		// connection to anything but localhost.
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/akhilbisht798/gocrony/internal/secrets"
+	"github.com/google/uuid"
+)
+
+const MAX_WEBHOOK_ATTEMPTS = 5
+
+const (
+	SignatureHeader = "X-Gocrony-Signature"
+	TimestampHeader = "X-Gocrony-Timestamp"
+	EventHeader     = "X-Gocrony-Event"
+	DeliveryHeader  = "X-Gocrony-Delivery"
+)
+
+var webhookRetryPolicy = models.RetryPolicy{
+	Backoff:             models.BackoffExponential,
+	InitialDelaySeconds: 30,
+	MaxDelaySeconds:     3600,
+	JitterSeconds:       10,
+}
+
+// RunEvent is the document sent to webhooks. The delivery id is sent in the
+// DeliveryHeader so receivers can deduplicate redelivered attempts.
+type RunEvent struct {
+	Event     models.WebhookEvent `json:"event"`
+	Job       EventJob            `json:"job"`
+	Run       EventRun            `json:"run"`
+	Timestamp time.Time           `json:"timestamp"`
+}
+
+type EventJob struct {
+	ID      uuid.UUID         `json:"id"`
+	Name    string            `json:"name"`
+	Type    models.JobType    `json:"type"`
+	Status  models.StatusType `json:"status"`
+	NextRun *time.Time        `json:"next_run,omitempty"`
+}
+
+type EventRun struct {
+	ID          uuid.UUID `json:"id"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	FiredAt     time.Time `json:"fired_at"`
+	Attempt     int       `json:"attempt"`
+	Status      string    `json:"status"`
+	StatusCode  int       `json:"status_code"`
+	DurationMs  int64     `json:"duration_ms"`
+	Response    string    `json:"response,omitempty"`
+}
+
+// Publish records a delivery for every webhook of the job's owner subscribed
+// to event and hands it to the workers.
+func Publish(ctx context.Context, userID uuid.UUID, event RunEvent) {
+	var webhooks []models.Webhook
+	err := db.DB.Where("user_id = ? AND enabled = ? AND (job_id IS NULL OR job_id = ?)", userID, true, event.Job.ID).
+		Find(&webhooks).Error
+	if err != nil {
+		log.Printf("Error fetching webhooks for job %s: %v", event.Job.ID, err)
+		return
+	}
+	if len(event.Run.Response) > 2*1024 {
+		event.Run.Response = event.Run.Response[:2*1024]
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Event) {
+			continue
+		}
+		delivery := models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			JobID:     event.Job.ID,
+			RunID:     event.Run.ID,
+			Event:     event.Event,
+			Status:    models.DeliveryQueued,
+		}
+		event.Timestamp = time.Now().UTC()
+		delivery.Payload, _ = json.Marshal(event)
+		if err := db.DB.Create(&delivery).Error; err != nil {
+			log.Printf("Error creating webhook delivery for %s: %v", webhook.ID, err)
+			continue
+		}
+		if err := scheduler.Enqueue(ctx, scheduler.WEBHOOK_PREFIX+delivery.ID.String()); err != nil {
+			// Leave it to the scheduler to pick up on its next pass.
+			now := time.Now().UTC()
+			db.DB.Model(&delivery).Updates(map[string]any{
+				"status":          models.DeliveryPending,
+				"next_attempt_at": now,
+			})
+		}
+	}
+}
+
+// Deliver makes one delivery attempt and schedules the next one on failure.
+func Deliver(ctx context.Context, client *http.Client, deliveryId string) error {
+	var delivery models.WebhookDelivery
+	if err := db.DB.Preload("Webhook").Where("id = ?", deliveryId).First(&delivery).Error; err != nil {
+		return fmt.Errorf("Error: delivery not found %s", err.Error())
+	}
+	// A redelivered queue entry may find the delivery already settled.
+	if delivery.Status == models.DeliveryDelivered || delivery.Status == models.DeliveryFailed {
+		return nil
+	}
+
+	statusCode, err := post(ctx, client, &delivery)
+	now := time.Now().UTC()
+	updates := map[string]any{
+		"attempts":    delivery.Attempts + 1,
+		"status_code": statusCode,
+		"error":       "",
+	}
+	if err == nil {
+		updates["status"] = models.DeliveryDelivered
+		updates["delivered_at"] = now
+		updates["next_attempt_at"] = nil
+	} else if delivery.Attempts+1 < MAX_WEBHOOK_ATTEMPTS {
+		updates["status"] = models.DeliveryPending
+		updates["error"] = err.Error()
+		updates["next_attempt_at"] = scheduler.GetRetryAt(webhookRetryPolicy, delivery.Attempts+1, now)
+	} else {
+		updates["status"] = models.DeliveryFailed
+		updates["error"] = err.Error()
+		updates["next_attempt_at"] = nil
+	}
+	if dbErr := db.DB.Model(&delivery).Updates(updates).Error; dbErr != nil {
+		log.Printf("Error updating webhook delivery %s: %v", delivery.ID, dbErr)
+	} else if updates["status"] == models.DeliveryPending {
+		scheduler.Wake(ctx)
+	}
+	return err
+}
+
+func post(ctx context.Context, client *http.Client, delivery *models.WebhookDelivery) (int, error) {
+	if !delivery.Webhook.Enabled {
+		return 0, errors.New("webhook disabled")
+	}
+	secret, err := secrets.DecryptWebhookSecret(&delivery.Webhook)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gocrony-webhook")
+	req.Header.Set(EventHeader, string(delivery.Event))
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(secret, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "timestamp.body". Receivers should
+// recompute it and reject stale timestamps to prevent replays.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
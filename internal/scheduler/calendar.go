@@ -0,0 +1,294 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/robfig/cron/v3"
+)
+
+// MAX_CALENDAR_SKIPS bounds how many excluded periods are stepped over
+// looking for a run the calendar allows.
+const MAX_CALENDAR_SKIPS = 1000
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// exclusion explains why a run was suppressed and when the excluded period
+// ends.
+type exclusion struct {
+	Reason string
+	Until  time.Time
+}
+
+// calendarRules is a Calendar with its dates and times parsed.
+type calendarRules struct {
+	calendar *models.Calendar
+	loc      *time.Location
+	ranges   []dateSpan
+	windows  []weeklySpan
+}
+
+type dateSpan struct {
+	start, end time.Time // end is exclusive
+	name       string
+}
+
+type weeklySpan struct {
+	days       map[time.Weekday]bool
+	start, end time.Duration // offsets into the day, end <= start crosses midnight
+	name       string
+}
+
+// parseCalendar checks a calendar's timezone, dates and windows.
+func parseCalendar(cal *models.Calendar) (*calendarRules, error) {
+	loc, err := time.LoadLocation(cal.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	rules := &calendarRules{calendar: cal, loc: loc}
+	for _, d := range cal.Dates {
+		day, err := time.ParseInLocation(time.DateOnly, d.Date, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: %w", d.Date, err)
+		}
+		rules.ranges = append(rules.ranges, dateSpan{start: day, end: day.AddDate(0, 0, 1), name: d.Name})
+	}
+	for _, r := range cal.Ranges {
+		start, err := time.ParseInLocation(time.DateOnly, r.Start, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range start %q: %w", r.Start, err)
+		}
+		end, err := time.ParseInLocation(time.DateOnly, r.End, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range end %q: %w", r.End, err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("range %s to %s ends before it starts", r.Start, r.End)
+		}
+		rules.ranges = append(rules.ranges, dateSpan{start: start, end: end.AddDate(0, 0, 1), name: r.Name})
+	}
+	for _, w := range cal.Windows {
+		span := weeklySpan{days: make(map[time.Weekday]bool), name: w.Name}
+		for _, day := range w.Days {
+			weekday, ok := weekdays[day]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q", day)
+			}
+			span.days[weekday] = true
+		}
+		if span.start, err = parseClock(w.Start); err != nil {
+			return nil, err
+		}
+		if span.end, err = parseClock(w.End); err != nil {
+			return nil, err
+		}
+		rules.windows = append(rules.windows, span)
+	}
+	return rules, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ValidateCalendar rejects calendars whose rules can't be evaluated, or whose
+// windows leave no time in the week for a run.
+func ValidateCalendar(cal *models.Calendar) error {
+	rules, err := parseCalendar(cal)
+	if err != nil {
+		return err
+	}
+	if rules.coversWeek() {
+		return errors.New("calendar windows exclude the whole week")
+	}
+	return nil
+}
+
+// coversWeek reports whether the weekly windows alone exclude every instant.
+// Date ranges always end, so they can't.
+func (c *calendarRules) coversWeek() bool {
+	windows := &calendarRules{calendar: c.calendar, loc: c.loc, windows: c.windows}
+	// Any full week works, one extra day covers windows crossing midnight.
+	t := time.Date(2024, time.January, 1, 0, 0, 0, 0, c.loc)
+	end := t.AddDate(0, 0, 8)
+	for i := 0; i < MAX_CALENDAR_SKIPS && t.Before(end); i++ {
+		ex, excluded := windows.excludes(t)
+		if !excluded || !ex.Until.After(t) {
+			return false
+		}
+		t = ex.Until
+	}
+	return !t.Before(end)
+}
+
+// excludes reports whether t falls in an excluded period.
+func (c *calendarRules) excludes(t time.Time) (exclusion, bool) {
+	local := t.In(c.loc)
+	for _, r := range c.ranges {
+		if !local.Before(r.start) && local.Before(r.end) {
+			reason := fmt.Sprintf("calendar %q excludes %s", c.calendar.Name, r.start.Format(time.DateOnly))
+			if last := r.end.AddDate(0, 0, -1); !last.Equal(r.start) {
+				reason += " to " + last.Format(time.DateOnly)
+			}
+			return exclusion{Reason: withName(reason, r.name), Until: r.end}, true
+		}
+	}
+
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.loc)
+	offset := local.Sub(midnight)
+	for _, w := range c.windows {
+		var from time.Time
+		switch {
+		case w.start < w.end && w.days[local.Weekday()] && offset >= w.start && offset < w.end:
+			from = midnight
+		case w.end <= w.start && w.days[local.Weekday()] && offset >= w.start:
+			from = midnight
+		case w.end <= w.start && w.days[local.AddDate(0, 0, -1).Weekday()] && offset < w.end:
+			from = midnight.AddDate(0, 0, -1)
+		default:
+			continue
+		}
+		until := from.Add(w.end)
+		if w.end <= w.start {
+			until = from.AddDate(0, 0, 1).Add(w.end)
+		}
+		reason := fmt.Sprintf("calendar %q excludes %s %s-%s", c.calendar.Name,
+			from.Weekday().String()[:3], clock(w.start), clock(w.end))
+		return exclusion{Reason: withName(reason, w.name), Until: until}, true
+	}
+	return exclusion{}, false
+}
+
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+func withName(reason string, name string) string {
+	if name == "" {
+		return reason
+	}
+	return reason + " (" + name + ")"
+}
+
+// calendarSchedule skips the occurrences its calendar excludes.
+type calendarSchedule struct {
+	inner cron.Schedule
+	rules *calendarRules
+}
+
+func (s calendarSchedule) Next(t time.Time) time.Time {
+	for i := 0; i < MAX_CALENDAR_SKIPS; i++ {
+		t = s.inner.Next(t)
+		if t.IsZero() {
+			return t
+		}
+		ex, excluded := s.rules.excludes(t)
+		if !excluded {
+			return t
+		}
+		// Jump to the end of the excluded period instead of walking every
+		// occurrence inside it.
+		t = ex.Until.In(t.Location()).Add(-time.Nanosecond)
+	}
+	return time.Time{}
+}
+
+// NextRunWithCalendar is NextScheduledRun with cal in place of the job's
+// stored calendar, so a calendar change can be checked before it is saved.
+func NextRunWithCalendar(job *models.Job, cal *models.Calendar, after time.Time) (*time.Time, error) {
+	sched, err := baseSchedule(job)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := parseCalendar(cal)
+	if err != nil {
+		return nil, err
+	}
+	return nextRun(job, calendarSchedule{inner: sched, rules: rules}, after)
+}
+
+// loadCalendar returns the parsed calendar of job, nil when it has none.
+func loadCalendar(job *models.Job) (*calendarRules, error) {
+	if job.CalendarID == nil {
+		return nil, nil
+	}
+	var cal models.Calendar
+	if err := db.DB.Where("id = ?", *job.CalendarID).First(&cal).Error; err != nil {
+		return nil, fmt.Errorf("loading calendar %s: %w", *job.CalendarID, err)
+	}
+	return parseCalendar(&cal)
+}
+
+// UpcomingRun is an occurrence of a job's schedule. Suppressed occurrences
+// carry the reason, and SuppressedUntil when the excluded period hides more
+// than one occurrence.
+type UpcomingRun struct {
+	At              time.Time  `json:"at"`
+	Suppressed      bool       `json:"suppressed"`
+	Reason          string     `json:"reason,omitempty"`
+	SuppressedUntil *time.Time `json:"suppressed_until,omitempty"`
+}
+
+// UpcomingRuns lists the next count runs of job after from, along with the
+// occurrences in between that its calendar suppresses.
+func UpcomingRuns(job *models.Job, from time.Time, count int) ([]UpcomingRun, error) {
+	if job.RunAt != nil {
+		return []UpcomingRun{{At: job.RunAt.UTC()}}, nil
+	}
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	sched, err := baseSchedule(job)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := loadCalendar(job)
+	if err != nil {
+		return nil, err
+	}
+	if job.OneTime() {
+		count = 1
+	}
+
+	var runs []UpcomingRun
+	t := from.In(loc)
+	allowed := 0
+	for skips := 0; allowed < count && skips < MAX_CALENDAR_SKIPS; {
+		t = sched.Next(t)
+		if t.IsZero() {
+			break
+		}
+		if rules != nil {
+			if ex, excluded := rules.excludes(t); excluded {
+				run := UpcomingRun{At: t.UTC(), Suppressed: true, Reason: ex.Reason}
+				if next := sched.Next(t); !next.IsZero() && next.Before(ex.Until) {
+					until := ex.Until.UTC()
+					run.SuppressedUntil = &until
+				}
+				runs = append(runs, run)
+				t = ex.Until.In(loc).Add(-time.Nanosecond)
+				skips++
+				continue
+			}
+		}
+		runs = append(runs, UpcomingRun{At: t.UTC()})
+		allowed++
+	}
+	return runs, nil
+}
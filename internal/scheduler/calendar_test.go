@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+var everyDay = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+func mustCalendar(t *testing.T, cal models.Calendar) *calendarRules {
+	t.Helper()
+	if cal.Name == "" {
+		cal.Name = "test"
+	}
+	rules, err := parseCalendar(&cal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules
+}
+
+func TestCalendarExcludes(t *testing.T) {
+	rules := mustCalendar(t, models.Calendar{
+		Timezone: "Europe/London",
+		Dates:    []models.CalendarDate{{Date: "2026-12-25", Name: "Christmas"}},
+		Ranges:   []models.CalendarRange{{Start: "2026-08-10", End: "2026-08-14"}},
+		Windows: []models.CalendarWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "12:00", End: "13:00"},
+			{Days: []string{"fri"}, Start: "22:00", End: "06:00"},
+		},
+	})
+	tests := []struct {
+		name     string
+		at       string
+		excluded bool
+		until    string
+	}{
+		{"excluded date", "2026-12-25T09:00:00Z", true, "2026-12-26T00:00:00Z"},
+		{"day after the date", "2026-12-26T09:00:00Z", false, ""},
+		{"first day of the range", "2026-08-10T00:30:00+01:00", true, "2026-08-15T00:00:00+01:00"},
+		{"last day of the range", "2026-08-14T23:59:00+01:00", true, "2026-08-15T00:00:00+01:00"},
+		{"after the range", "2026-08-15T12:00:00+01:00", false, ""},
+		{"inside a weekday window", "2026-01-07T12:30:00Z", true, "2026-01-07T13:00:00Z"},
+		{"window end is exclusive", "2026-01-07T13:00:00Z", false, ""},
+		{"window day not listed", "2026-01-10T12:30:00Z", false, ""},
+		{"window in summer time", "2026-07-08T11:30:00Z", true, "2026-07-08T12:00:00Z"},
+		{"overnight window before midnight", "2026-01-09T23:00:00Z", true, "2026-01-10T06:00:00Z"},
+		{"overnight window after midnight", "2026-01-10T05:59:00Z", true, "2026-01-10T06:00:00Z"},
+		{"overnight window ended", "2026-01-10T06:00:00Z", false, ""},
+		{"overnight window only follows its day", "2026-01-11T03:00:00Z", false, ""},
+	}
+	for _, tt := range tests {
+		ex, excluded := rules.excludes(mustTime(t, tt.at))
+		if excluded != tt.excluded {
+			t.Errorf("%s: excluded = %v, want %v", tt.name, excluded, tt.excluded)
+			continue
+		}
+		if excluded && !ex.Until.Equal(mustTime(t, tt.until)) {
+			t.Errorf("%s: until = %s, want %s", tt.name, ex.Until, tt.until)
+		}
+		if excluded && ex.Reason == "" {
+			t.Errorf("%s: missing reason", tt.name)
+		}
+	}
+}
+
+func TestCalendarScheduleNext(t *testing.T) {
+	rules := mustCalendar(t, models.Calendar{
+		Timezone: "UTC",
+		Ranges:   []models.CalendarRange{{Start: "2026-01-05", End: "2026-01-09"}},
+		Windows:  []models.CalendarWindow{{Days: []string{"sat", "sun"}, Start: "00:00", End: "00:00"}},
+	})
+	inner, err := ParseSchedule("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := calendarSchedule{inner: inner, rules: rules}
+	tests := []struct {
+		name  string
+		after string
+		want  string
+	}{
+		{"allowed day", "2026-01-01T10:00:00Z", "2026-01-02T09:00:00Z"},
+		{"skips the weekend and the range", "2026-01-02T10:00:00Z", "2026-01-12T09:00:00Z"},
+		{"skips the weekend", "2026-01-16T10:00:00Z", "2026-01-19T09:00:00Z"},
+	}
+	for _, tt := range tests {
+		got := s.Next(mustTime(t, tt.after))
+		if want := mustTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("%s: Next = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestValidateCalendar(t *testing.T) {
+	tests := []struct {
+		name    string
+		cal     models.Calendar
+		wantErr bool
+	}{
+		{"empty", models.Calendar{Timezone: "UTC"}, false},
+		{"bad timezone", models.Calendar{Timezone: "Nowhere/Else"}, true},
+		{"bad date", models.Calendar{Timezone: "UTC", Dates: []models.CalendarDate{{Date: "2026-02-30"}}}, true},
+		{"range ends before it starts", models.Calendar{Timezone: "UTC", Ranges: []models.CalendarRange{{Start: "2026-02-10", End: "2026-02-01"}}}, true},
+		{"bad day", models.Calendar{Timezone: "UTC", Windows: []models.CalendarWindow{{Days: []string{"funday"}, Start: "01:00", End: "02:00"}}}, true},
+		{"bad clock", models.Calendar{Timezone: "UTC", Windows: []models.CalendarWindow{{Days: everyDay, Start: "25:00", End: "02:00"}}}, true},
+		{"every day, all day", models.Calendar{Timezone: "UTC", Windows: []models.CalendarWindow{{Days: everyDay, Start: "00:00", End: "00:00"}}}, true},
+		{"every day, all day from 09:00", models.Calendar{Timezone: "Europe/London", Windows: []models.CalendarWindow{{Days: everyDay, Start: "09:00", End: "09:00"}}}, true},
+		{"two windows cover the week", models.Calendar{Timezone: "UTC", Windows: []models.CalendarWindow{
+			{Days: everyDay, Start: "22:00", End: "06:00"},
+			{Days: everyDay, Start: "06:00", End: "22:00"},
+		}}, true},
+		{"one day left free", models.Calendar{Timezone: "UTC", Windows: []models.CalendarWindow{{Days: everyDay[:6], Start: "00:00", End: "00:00"}}}, false},
+		{"a minute left free", models.Calendar{Timezone: "UTC", Windows: []models.CalendarWindow{{Days: everyDay, Start: "00:00", End: "23:59"}}}, false},
+	}
+	for _, tt := range tests {
+		tt.cal.Name = tt.name
+		err := ValidateCalendar(&tt.cal)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateCalendar error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
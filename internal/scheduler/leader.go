@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/config"
+	"github.com/akhilbisht798/gocrony/internal/cache"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// LEADER_KEY holds the id of the instance allowed to run the scheduler loop,
+// the reaper and the other singleton passes.
+const LEADER_KEY = "jobs:leader"
+
+const DEFAULT_LEADER_LEASE = 15 * time.Second
+
+// renewLeaseScript extends the lease only while this instance still holds it.
+var renewLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Elector competes for a redis lease so only one of several replicas
+// schedules jobs. The lease is renewed every third of its length; when the
+// leader dies the lease expires and another replica takes over.
+type Elector struct {
+	ID     string
+	lease  time.Duration
+	leader atomic.Bool
+}
+
+// NewElector reads the lease length from SCHEDULER_LEASE_SECONDS.
+func NewElector(id string) *Elector {
+	lease := DEFAULT_LEADER_LEASE
+	if seconds := config.GetEnvInt("SCHEDULER_LEASE_SECONDS", 0); seconds > 0 {
+		lease = time.Duration(seconds) * time.Second
+	}
+	return &Elector{ID: id, lease: lease}
+}
+
+// InstanceID names this process in the lease, the hostname makes the status
+// endpoint readable.
+func InstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8])
+}
+
+// IsLeader reports whether this instance held the lease at its last renewal.
+func (e *Elector) IsLeader() bool {
+	return e != nil && e.leader.Load()
+}
+
+// Run campaigns for the lease until ctx is done and then gives it up, so a
+// clean shutdown fails over without waiting for the lease to expire.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.lease / 3)
+	defer ticker.Stop()
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) campaign(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.lease/3)
+	defer cancel()
+
+	var held bool
+	var err error
+	if e.leader.Load() {
+		var renewed int64
+		renewed, err = renewLeaseScript.Run(ctx, cache.Rbd, []string{LEADER_KEY}, e.ID, e.lease.Milliseconds()).Int64()
+		held = renewed == 1
+	} else {
+		held, err = cache.Rbd.SetNX(ctx, LEADER_KEY, e.ID, e.lease).Result()
+	}
+	if err != nil {
+		// Without redis we can't tell whether someone else took over, so
+		// stop scheduling rather than risk running twice.
+		log.Printf("Error renewing scheduler lease: %v", err)
+		held = false
+	}
+	if was := e.leader.Swap(held); was != held {
+		if held {
+			log.Printf("instance %s became scheduler leader", e.ID)
+		} else {
+			log.Printf("instance %s lost scheduler leadership", e.ID)
+		}
+	}
+}
+
+func (e *Elector) release() {
+	if !e.leader.Swap(false) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := releaseLeaseScript.Run(ctx, cache.Rbd, []string{LEADER_KEY}, e.ID).Err(); err != nil {
+		log.Printf("Error releasing scheduler lease: %v", err)
+	}
+}
+
+type LeaderStatus struct {
+	Leader           string `json:"leader"`
+	LeaseExpiresInMs int64  `json:"lease_expires_in_ms"`
+}
+
+// CurrentLeader reads the lease, Leader is empty while nobody holds it.
+func CurrentLeader(ctx context.Context) (LeaderStatus, error) {
+	var status LeaderStatus
+	leader, err := cache.Rbd.Get(ctx, LEADER_KEY).Result()
+	if errors.Is(err, redis.Nil) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	ttl, err := cache.Rbd.PTTL(ctx, LEADER_KEY).Result()
+	if err != nil {
+		return status, err
+	}
+	status.Leader = leader
+	status.LeaseExpiresInMs = max(ttl.Milliseconds(), 0)
+	return status, nil
+}
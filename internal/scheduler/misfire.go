@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// MISFIRE_GRACE is how late an occurrence may fire before it counts as
+	// missed; a scheduler pass normally picks a job up well within it.
+	MISFIRE_GRACE = 1 * time.Minute
+	// MAX_MISFIRE_SCAN bounds how many of the latest missed occurrences are
+	// kept, older ones are skipped without being listed. It has to cover the
+	// largest max_catch_up.
+	MAX_MISFIRE_SCAN = 1000
+	// MAX_SKIPPED_LOGS bounds the log entries written for one pass, older
+	// occurrences are summarised in a single entry.
+	MAX_SKIPPED_LOGS = 100
+)
+
+type skippedRun struct {
+	At     time.Time
+	Reason string
+}
+
+// misfirePlan is what a scheduler pass does with a due job.
+type misfirePlan struct {
+	// RunAt is the occurrence to run now, nil when nothing runs.
+	RunAt *time.Time
+	// NextRun is the job's next_run when nothing runs.
+	NextRun *time.Time
+	Skipped []skippedRun
+	// Dropped counts skipped occurrences older than the MAX_MISFIRE_SCAN
+	// kept ones. When Jumped is set the scan skipped ahead and Dropped is
+	// only a lower bound.
+	Dropped int
+	Jumped  bool
+}
+
+// planMisfire applies the job's misfire policy and starting deadline to the
+// occurrences between its next_run and now.
+func planMisfire(job *models.Job, now time.Time) (misfirePlan, error) {
+	var plan misfirePlan
+	if job.NextRun == nil {
+		return plan, nil
+	}
+	// A one-time job has a single occurrence, which only its starting
+	// deadline can skip.
+	if job.OneTime() {
+		deadline := time.Duration(job.StartingDeadlineSeconds) * time.Second
+		if deadline > 0 && now.Sub(*job.NextRun) > deadline {
+			plan.Skipped = append(plan.Skipped, skippedRun{
+				At:     *job.NextRun,
+				Reason: fmt.Sprintf("missed the starting deadline of %s", deadline),
+			})
+			return plan, nil
+		}
+		runAt := *job.NextRun
+		plan.RunAt = &runAt
+		return plan, nil
+	}
+
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		return plan, err
+	}
+	sched, err := JobSchedule(job)
+	if err != nil {
+		return plan, err
+	}
+
+	var slots []time.Time
+	next := job.NextRun.In(loc)
+	// next_run may have been computed before the calendar last changed.
+	if cs, ok := sched.(calendarSchedule); ok {
+		if ex, excluded := cs.rules.excludes(next); excluded {
+			plan.Skipped = append(plan.Skipped, skippedRun{At: next.UTC(), Reason: ex.Reason})
+			next = sched.Next(next)
+		}
+	}
+	for !next.IsZero() && !next.After(now) {
+		if len(slots) == MAX_MISFIRE_SCAN {
+			// Rather than stepping through every occurrence of a long
+			// outage, jump to where the last MAX_MISFIRE_SCAN of them
+			// should start, judging by how far apart the scanned ones are.
+			ahead := sched.Next(now.In(loc).Add(-slots[len(slots)-1].Sub(slots[0])))
+			if ahead.After(next) && !ahead.After(now) {
+				plan.Dropped += len(slots)
+				plan.Jumped = true
+				slots = slots[:0]
+				next = ahead
+				continue
+			}
+			slots = slots[1:]
+			plan.Dropped++
+		}
+		slots = append(slots, next.UTC())
+		next = sched.Next(next)
+	}
+	if !next.IsZero() {
+		future := withJitter(job, next.UTC())
+		plan.NextRun = &future
+	}
+	if len(slots) == 0 {
+		return plan, nil
+	}
+
+	deadline := time.Duration(job.StartingDeadlineSeconds) * time.Second
+	pastDeadline := func(s time.Time) bool {
+		return deadline > 0 && now.Sub(s) > deadline
+	}
+	latest := len(slots) - 1
+
+	// The occurrences from start on run, everything before it is skipped.
+	start := len(slots)
+	switch job.MisfirePolicy {
+	case models.MisfireSkip:
+		if now.Sub(slots[latest]) <= MISFIRE_GRACE && !pastDeadline(slots[latest]) {
+			start = latest
+		}
+	case models.MisfireRunAll:
+		maxCatchUp := job.MaxCatchUp
+		if maxCatchUp <= 0 {
+			maxCatchUp = models.DEFAULT_MAX_CATCH_UP
+		}
+		start = max(len(slots)-maxCatchUp, 0)
+		for start < len(slots) && pastDeadline(slots[start]) {
+			start++
+		}
+	default:
+		if !pastDeadline(slots[latest]) {
+			start = latest
+		}
+	}
+
+	for _, s := range slots[:start] {
+		var reason string
+		switch {
+		case pastDeadline(s):
+			reason = fmt.Sprintf("missed the starting deadline of %s", deadline)
+		case job.MisfirePolicy == models.MisfireSkip:
+			reason = "missed while the scheduler was not running, misfire policy is skip"
+		case job.MisfirePolicy == models.MisfireRunAll:
+			reason = "missed while the scheduler was not running, beyond max catch up"
+		default:
+			reason = fmt.Sprintf("missed while the scheduler was not running, covered by the run at %s", slots[latest].Format(time.RFC3339))
+		}
+		plan.Skipped = append(plan.Skipped, skippedRun{At: s, Reason: reason})
+	}
+	if start < len(slots) {
+		plan.RunAt = &slots[start]
+	}
+	return plan, nil
+}
+
+// logSkippedRuns records every occurrence the plan skipped so gaps in a job's
+// history can be told apart from runs that never happened.
+func logSkippedRuns(job *models.Job, plan misfirePlan) {
+	skipped := plan.Skipped
+	var summary *models.Logs
+	omitted := max(len(skipped)-MAX_SKIPPED_LOGS, 0)
+	if omitted+plan.Dropped > 0 {
+		count := fmt.Sprint(omitted + plan.Dropped)
+		if plan.Jumped {
+			count = "more than " + count
+		}
+		summary = &models.Logs{
+			RunID:    uuid.New(),
+			Status:   models.LogStatusSkipped,
+			Response: fmt.Sprintf("%s earlier occurrences missed while the scheduler was not running", count),
+			RunAt:    *job.NextRun,
+			JobID:    job.ID,
+		}
+		skipped = skipped[omitted:]
+	}
+
+	entries := make([]models.Logs, 0, len(skipped)+1)
+	if summary != nil {
+		entries = append(entries, *summary)
+	}
+	for _, s := range skipped {
+		entries = append(entries, models.Logs{
+			RunID:    uuid.New(),
+			Status:   models.LogStatusSkipped,
+			Response: s.Reason,
+			RunAt:    s.At,
+			JobID:    job.ID,
+		})
+	}
+	if len(entries) == 0 {
+		return
+	}
+	if err := db.DB.CreateInBatches(&entries, 100).Error; err != nil {
+		log.Printf("Error: creating skipped run logs for jobId %s: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,214 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestPlanMisfire(t *testing.T) {
+	// Every test job runs at the top of each hour and was last due at 09:00.
+	now := mustTime(t, "2026-01-01T12:00:30Z")
+	nextRun := mustTime(t, "2026-01-01T09:00:00Z")
+	tests := []struct {
+		name     string
+		job      models.Job
+		wantRun  string
+		wantNext string
+		skipped  []string
+	}{
+		{
+			name:    "run_once runs the latest and skips the rest",
+			job:     models.Job{MisfirePolicy: models.MisfireRunOnce},
+			wantRun: "2026-01-01T12:00:00Z",
+			skipped: []string{"2026-01-01T09:00:00Z", "2026-01-01T10:00:00Z", "2026-01-01T11:00:00Z"},
+		},
+		{
+			name:    "empty policy behaves like run_once",
+			job:     models.Job{},
+			wantRun: "2026-01-01T12:00:00Z",
+			skipped: []string{"2026-01-01T09:00:00Z", "2026-01-01T10:00:00Z", "2026-01-01T11:00:00Z"},
+		},
+		{
+			name:     "run_once past the starting deadline runs nothing",
+			job:      models.Job{MisfirePolicy: models.MisfireRunOnce, StartingDeadlineSeconds: 10},
+			wantNext: "2026-01-01T13:00:00Z",
+			skipped:  []string{"2026-01-01T09:00:00Z", "2026-01-01T10:00:00Z", "2026-01-01T11:00:00Z", "2026-01-01T12:00:00Z"},
+		},
+		{
+			name:    "run_all starts with the oldest",
+			job:     models.Job{MisfirePolicy: models.MisfireRunAll},
+			wantRun: "2026-01-01T09:00:00Z",
+		},
+		{
+			name:    "run_all is bounded by max catch up",
+			job:     models.Job{MisfirePolicy: models.MisfireRunAll, MaxCatchUp: 2},
+			wantRun: "2026-01-01T11:00:00Z",
+			skipped: []string{"2026-01-01T09:00:00Z", "2026-01-01T10:00:00Z"},
+		},
+		{
+			name:    "run_all skips occurrences past the starting deadline",
+			job:     models.Job{MisfirePolicy: models.MisfireRunAll, StartingDeadlineSeconds: 7200},
+			wantRun: "2026-01-01T11:00:00Z",
+			skipped: []string{"2026-01-01T09:00:00Z", "2026-01-01T10:00:00Z"},
+		},
+		{
+			name:    "skip runs the latest within the grace",
+			job:     models.Job{MisfirePolicy: models.MisfireSkip},
+			wantRun: "2026-01-01T12:00:00Z",
+			skipped: []string{"2026-01-01T09:00:00Z", "2026-01-01T10:00:00Z", "2026-01-01T11:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		tt.job.Schedule = "0 * * * *"
+		tt.job.Timezone = "UTC"
+		tt.job.Recurring = true
+		tt.job.NextRun = timePtr(nextRun)
+		checkPlan(t, tt.name, &tt.job, now, tt.wantRun, tt.wantNext, tt.skipped)
+	}
+}
+
+func TestPlanMisfireSkipOutsideGrace(t *testing.T) {
+	job := models.Job{
+		Schedule:      "0 * * * *",
+		Timezone:      "UTC",
+		Recurring:     true,
+		MisfirePolicy: models.MisfireSkip,
+		NextRun:       timePtr(mustTime(t, "2026-01-01T11:00:00Z")),
+	}
+	now := mustTime(t, "2026-01-01T12:05:00Z")
+	checkPlan(t, "skip outside the grace", &job, now, "", "2026-01-01T13:00:00Z",
+		[]string{"2026-01-01T11:00:00Z", "2026-01-01T12:00:00Z"})
+}
+
+func TestPlanMisfireOnTime(t *testing.T) {
+	job := models.Job{
+		Schedule:  "0 * * * *",
+		Timezone:  "UTC",
+		Recurring: true,
+		NextRun:   timePtr(mustTime(t, "2026-01-01T12:00:00Z")),
+	}
+	checkPlan(t, "on time", &job, mustTime(t, "2026-01-01T12:00:01Z"), "2026-01-01T12:00:00Z", "", nil)
+}
+
+func TestPlanMisfireAcrossDST(t *testing.T) {
+	// 02:30 does not exist in New York on 2026-03-08, the run that day fires
+	// when the clocks jump to 03:00.
+	job := models.Job{
+		Schedule:      "30 2 * * *",
+		Timezone:      "America/New_York",
+		Recurring:     true,
+		MisfirePolicy: models.MisfireRunAll,
+		NextRun:       timePtr(mustTime(t, "2026-03-07T07:30:00Z")),
+	}
+	now := mustTime(t, "2026-03-09T12:00:00Z")
+	plan, err := planMisfire(&job, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.RunAt == nil || !plan.RunAt.Equal(mustTime(t, "2026-03-07T07:30:00Z")) {
+		t.Fatalf("RunAt = %v, want the 2026-03-07 occurrence", plan.RunAt)
+	}
+	if len(plan.Skipped) != 0 {
+		t.Errorf("Skipped = %v, want none", plan.Skipped)
+	}
+}
+
+func TestPlanMisfireOneTime(t *testing.T) {
+	runAt := mustTime(t, "2026-01-01T09:00:00Z")
+	tests := []struct {
+		name     string
+		deadline int
+		now      string
+		wantRun  string
+		skipped  []string
+	}{
+		{"runs late without a deadline", 0, "2026-01-02T09:00:00Z", "2026-01-01T09:00:00Z", nil},
+		{"runs within the deadline", 3600, "2026-01-01T09:30:00Z", "2026-01-01T09:00:00Z", nil},
+		{"skipped past the deadline", 3600, "2026-01-01T10:30:00Z", "", []string{"2026-01-01T09:00:00Z"}},
+	}
+	for _, tt := range tests {
+		job := models.Job{
+			RunAt:                   timePtr(runAt),
+			NextRun:                 timePtr(runAt),
+			Timezone:                "UTC",
+			StartingDeadlineSeconds: tt.deadline,
+		}
+		checkPlan(t, tt.name, &job, mustTime(t, tt.now), tt.wantRun, "", tt.skipped)
+	}
+}
+
+func checkPlan(t *testing.T, name string, job *models.Job, now time.Time, wantRun string, wantNext string, skipped []string) {
+	t.Helper()
+	plan, err := planMisfire(job, now)
+	if err != nil {
+		t.Errorf("%s: %v", name, err)
+		return
+	}
+	switch {
+	case wantRun == "" && plan.RunAt != nil:
+		t.Errorf("%s: RunAt = %s, want nothing to run", name, plan.RunAt)
+	case wantRun != "" && (plan.RunAt == nil || !plan.RunAt.Equal(mustTime(t, wantRun))):
+		t.Errorf("%s: RunAt = %v, want %s", name, plan.RunAt, wantRun)
+	}
+	if wantNext != "" && (plan.NextRun == nil || !plan.NextRun.Equal(mustTime(t, wantNext))) {
+		t.Errorf("%s: NextRun = %v, want %s", name, plan.NextRun, wantNext)
+	}
+	if len(plan.Skipped) != len(skipped) {
+		t.Errorf("%s: skipped %d occurrences, want %d: %v", name, len(plan.Skipped), len(skipped), plan.Skipped)
+		return
+	}
+	for i, s := range plan.Skipped {
+		if !s.At.Equal(mustTime(t, skipped[i])) || s.Reason == "" {
+			t.Errorf("%s: skipped[%d] = %s %q, want %s", name, i, s.At, s.Reason, skipped[i])
+		}
+	}
+}
+
+func TestPlanMisfireLongOutage(t *testing.T) {
+	// A per-second job down for 30 days has ~2.6M missed occurrences, the
+	// scan jumps ahead instead of visiting each of them.
+	tests := []struct {
+		name    string
+		policy  models.MisfirePolicy
+		wantRun string
+	}{
+		{"run_once runs the latest", models.MisfireRunOnce, "2026-01-31T00:00:00Z"},
+		{"run_all keeps max catch up", models.MisfireRunAll, "2026-01-30T23:59:58Z"},
+	}
+	for _, tt := range tests {
+		job := models.Job{
+			Schedule:      "* * * * * *",
+			Timezone:      "Europe/London",
+			Recurring:     true,
+			MisfirePolicy: tt.policy,
+			MaxCatchUp:    3,
+			NextRun:       timePtr(mustTime(t, "2026-01-01T00:00:00Z")),
+		}
+		start := time.Now()
+		plan, err := planMisfire(&job, mustTime(t, "2026-01-31T00:00:00.5Z"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: planMisfire took %s", tt.name, elapsed)
+		}
+		if plan.RunAt == nil || !plan.RunAt.Equal(mustTime(t, tt.wantRun)) {
+			t.Errorf("%s: RunAt = %v, want %s", tt.name, plan.RunAt, tt.wantRun)
+		}
+		if !plan.Jumped || plan.Dropped < MAX_MISFIRE_SCAN {
+			t.Errorf("%s: Jumped = %v, Dropped = %d", tt.name, plan.Jumped, plan.Dropped)
+		}
+		if len(plan.Skipped) >= MAX_MISFIRE_SCAN {
+			t.Errorf("%s: listed %d skipped occurrences, want fewer than %d", tt.name, len(plan.Skipped), MAX_MISFIRE_SCAN)
+		}
+		if plan.NextRun == nil || !plan.NextRun.Equal(mustTime(t, "2026-01-31T00:00:01Z")) {
+			t.Errorf("%s: NextRun = %v", tt.name, plan.NextRun)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/config"
+	"github.com/akhilbisht798/gocrony/internal/cache"
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_STUCK_JOB_THRESHOLD = 30 * time.Minute
+	REAPER_INTERVAL             = 1 * time.Minute
+)
+
+func StuckJobThreshold() time.Duration {
+	seconds := config.GetEnvInt("STUCK_JOB_THRESHOLD_SECONDS", 0)
+	if seconds <= 0 {
+		return DEFAULT_STUCK_JOB_THRESHOLD
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// StuckJob is the part of a stuck job the admin endpoint shows, payloads may
+// carry credentials and belong to other users.
+type StuckJob struct {
+	ID       uuid.UUID         `json:"id"`
+	Name     string            `json:"name"`
+	UserID   uuid.UUID         `json:"user_id"`
+	Status   models.StatusType `json:"status"`
+	QueuedAt *time.Time        `json:"queued_at,omitempty"`
+}
+
+// FindStuckJobs returns jobs that have been queued for longer than threshold
+// and are not currently being executed by a worker. Jobs queued before
+// queued_at was tracked are always considered stuck.
+func FindStuckJobs(ctx context.Context, threshold time.Duration) ([]StuckJob, error) {
+	var jobs []StuckJob
+	cutoff := time.Now().UTC().Add(-threshold)
+	err := db.DB.WithContext(ctx).Model(&models.Job{}).
+		Select("id", "name", "user_id", "status", "queued_at").
+		Where("status = ? AND (queued_at IS NULL OR queued_at < ?)", models.StatusQueued, cutoff).
+		Find(&jobs).Error
+	if err != nil {
+		return nil, fmt.Errorf("Error: failed to fetch stuck jobs %w", err)
+	}
+
+	stuck := jobs[:0]
+	for _, job := range jobs {
+		executing, err := isExecuting(ctx, job.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		if !executing {
+			stuck = append(stuck, job)
+		}
+	}
+	return stuck, nil
+}
+
+// isExecuting reports whether a worker is still heartbeating a delivery of
+// jobId.
+func isExecuting(ctx context.Context, jobId string) (bool, error) {
+	now := time.Now().Unix()
+	iter := cache.Rbd.ZScan(ctx, INFLIGHT_QUEUE, 0, jobId+DELIVERY_SEPARATOR+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		member := iter.Val()
+		if !iter.Next(ctx) {
+			break
+		}
+		deadline, err := strconv.ParseFloat(iter.Val(), 64)
+		if err == nil && EntryName(member) == jobId && int64(deadline) > now {
+			return true, nil
+		}
+	}
+	return false, iter.Err()
+}
+
+// StartReaper periodically resets stuck jobs to pending so the scheduler picks
+// them up again on its next pass, and deletes completed jobs past their
+// retention. Like the scheduler it only runs on the leader.
+func StartReaper(ctx context.Context, elector *Elector) {
+	log.Println("stuck job reaper started")
+	ticker := time.NewTicker(REAPER_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !elector.IsLeader() {
+				continue
+			}
+			if err := recoverStuckJobs(ctx); err != nil {
+				log.Printf("Error recovering stuck jobs: %v", err)
+			}
+			if err := purgeCompletedJobs(ctx); err != nil {
+				log.Printf("Error deleting completed jobs: %v", err)
+			}
+		}
+	}
+}
+
+func recoverStuckJobs(ctx context.Context) error {
+	threshold := StuckJobThreshold()
+	jobs, err := FindStuckJobs(ctx, threshold)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		// Drop any copy still waiting in redis, otherwise the job would run
+		// twice once it is rescheduled.
+		if err := removeQueued(ctx, job.ID.String()); err != nil {
+			log.Printf("Error removing stuck job %s from queue: %v", job.ID, err)
+			continue
+		}
+		tx := db.DB.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, models.StatusQueued).
+			Updates(map[string]any{
+				"status":    models.StatusPending,
+				"queued_at": nil,
+			})
+		if tx.Error != nil {
+			log.Printf("Error resetting stuck job %s: %v", job.ID, tx.Error)
+			continue
+		}
+		if tx.RowsAffected == 0 {
+			continue
+		}
+
+		since := "an unknown time"
+		if job.QueuedAt != nil {
+			since = time.Since(*job.QueuedAt).Round(time.Second).String()
+		}
+		logEntry := models.Logs{
+			Status:   models.LogStatusRecovered,
+			Response: fmt.Sprintf("job was queued for %s without being executed (threshold %s), reset to pending", since, threshold),
+			RunAt:    time.Now().UTC(),
+			JobID:    job.ID,
+		}
+		if err := db.DB.Create(&logEntry).Error; err != nil {
+			log.Println("Error: creating log for jobId", job.ID)
+		}
+		log.Printf("Recovered stuck job %s", job.ID)
+	}
+	return nil
+}
+
+// purgeCompletedJobs deletes completed jobs whose delete_after_seconds have
+// passed since their run, their logs go with them.
+func purgeCompletedJobs(ctx context.Context) error {
+	tx := db.DB.WithContext(ctx).
+		Where("status = ? AND delete_after_seconds > 0 AND last_run < ? - make_interval(secs => delete_after_seconds)",
+			models.StatusCompleted, time.Now().UTC()).
+		Delete(&models.Job{})
+	if tx.Error != nil {
+		return fmt.Errorf("Error: failed to delete completed jobs %w", tx.Error)
+	}
+	if tx.RowsAffected > 0 {
+		log.Printf("Deleted %d completed jobs past their retention", tx.RowsAffected)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+// ValidateRetryPolicy checks the parts of a policy struct tags can't express.
+func ValidateRetryPolicy(policy models.RetryPolicy) error {
+	if policy.MaxDelaySeconds > 0 && policy.InitialDelaySeconds > policy.MaxDelaySeconds {
+		return fmt.Errorf("initial_delay_seconds must not exceed max_delay_seconds")
+	}
+	return nil
+}
+
+// GetRetryAt returns when the retry-th retry (starting at 1) should run.
+func GetRetryAt(policy models.RetryPolicy, retry int, now time.Time) time.Time {
+	policy = policy.WithDefaults()
+	// Rows saved before the bounds existed may hold anything, clamp so the
+	// durations below can't overflow.
+	policy.InitialDelaySeconds = min(policy.InitialDelaySeconds, models.MAX_RETRY_DELAY)
+	policy.MaxDelaySeconds = min(policy.MaxDelaySeconds, models.MAX_RETRY_DELAY)
+	policy.JitterSeconds = min(policy.JitterSeconds, models.MAX_RETRY_JITTER)
+	initial := time.Duration(policy.InitialDelaySeconds) * time.Second
+	maxDelay := time.Duration(policy.MaxDelaySeconds) * time.Second
+
+	delay := initial
+	switch policy.Backoff {
+	case models.BackoffLinear:
+		delay = initial * time.Duration(retry)
+	case models.BackoffExponential:
+		// Stop doubling once past the cap so the shift can't overflow.
+		for i := 1; i < retry && delay < maxDelay; i++ {
+			delay *= 2
+		}
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if policy.JitterSeconds > 0 {
+		delay += time.Duration(rand.Int64N(int64(policy.JitterSeconds)*int64(time.Second) + 1))
+	}
+	return now.Add(delay)
+}
@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+func TestGetRetryAt(t *testing.T) {
+	now := mustTime(t, "2026-01-01T00:00:00Z")
+	tests := []struct {
+		name   string
+		policy models.RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{"defaults", models.RetryPolicy{}, 1, time.Minute},
+		{"exponential", models.RetryPolicy{InitialDelaySeconds: 10}, 3, 40 * time.Second},
+		{"exponential is capped", models.RetryPolicy{InitialDelaySeconds: 10, MaxDelaySeconds: 30}, 10, 30 * time.Second},
+		{"linear", models.RetryPolicy{Backoff: models.BackoffLinear, InitialDelaySeconds: 10}, 3, 30 * time.Second},
+		{"fixed", models.RetryPolicy{Backoff: models.BackoffFixed, InitialDelaySeconds: 10}, 5, 10 * time.Second},
+		{"huge delays are clamped", models.RetryPolicy{Backoff: models.BackoffFixed, InitialDelaySeconds: 1 << 40, MaxDelaySeconds: 1 << 40}, 1, models.MAX_RETRY_DELAY * time.Second},
+	}
+	for _, tt := range tests {
+		if got := GetRetryAt(tt.policy, tt.retry, now).Sub(now); got != tt.want {
+			t.Errorf("%s: delay = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetRetryAtJitter(t *testing.T) {
+	now := mustTime(t, "2026-01-01T00:00:00Z")
+	tests := []struct {
+		name   string
+		jitter int
+		max    time.Duration
+	}{
+		{"within the window", 5, 65 * time.Second},
+		{"huge window is clamped", 1 << 40, time.Minute + models.MAX_RETRY_JITTER*time.Second},
+	}
+	for _, tt := range tests {
+		policy := models.RetryPolicy{Backoff: models.BackoffFixed, JitterSeconds: tt.jitter}
+		for i := 0; i < 100; i++ {
+			got := GetRetryAt(policy, 1, now).Sub(now)
+			if got < time.Minute || got > tt.max {
+				t.Fatalf("%s: delay = %s, want between 1m and %s", tt.name, got, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/robfig/cron/v3"
+)
+
+const MIN_INTERVAL = 1 * time.Second
+
+// anchoredInterval fires every Every counted from Anchor, so runs keep their
+// rhythm however long each one takes.
+type anchoredInterval struct {
+	Anchor time.Time
+	Every  time.Duration
+}
+
+func (s anchoredInterval) Next(t time.Time) time.Time {
+	if t.Before(s.Anchor) {
+		return s.Anchor.In(t.Location())
+	}
+	elapsed := t.Sub(s.Anchor)
+	next := s.Anchor.Add((elapsed/s.Every + 1) * s.Every)
+	return next.In(t.Location())
+}
+
+// fixedDelay fires Delay after the time it is given, which for a finished
+// run is its completion.
+type fixedDelay struct {
+	Delay time.Duration
+}
+
+func (s fixedDelay) Next(t time.Time) time.Time {
+	return t.Add(s.Delay)
+}
+
+// ParseInterval accepts "@every 90m" or a bare duration such as "90m".
+func ParseInterval(schedule string) (time.Duration, error) {
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(schedule), "@every"))
+	every, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q: %w", schedule, err)
+	}
+	if every < MIN_INTERVAL {
+		return 0, fmt.Errorf("interval must be at least %s", MIN_INTERVAL)
+	}
+	return every, nil
+}
+
+// JobSchedule interprets the job's schedule according to its schedule kind,
+// skipping the occurrences its calendar excludes.
+func JobSchedule(job *models.Job) (cron.Schedule, error) {
+	sched, err := baseSchedule(job)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := loadCalendar(job)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		return sched, nil
+	}
+	return calendarSchedule{inner: sched, rules: rules}, nil
+}
+
+func baseSchedule(job *models.Job) (cron.Schedule, error) {
+	switch job.ScheduleKind {
+	case models.ScheduleInterval:
+		every, err := ParseInterval(job.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		anchor := job.CreatedAt
+		if anchor.IsZero() {
+			anchor = time.Now()
+		}
+		return anchoredInterval{Anchor: anchor.UTC().Truncate(time.Second), Every: every}, nil
+	case models.ScheduleFixedDelay:
+		delay, err := ParseInterval(job.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		return fixedDelay{Delay: delay}, nil
+	case "", models.ScheduleCron:
+		schedule, err := ExpandHash(job.Schedule, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		return ParseSchedule(schedule)
+	}
+	return nil, fmt.Errorf("unknown schedule kind %q", job.ScheduleKind)
+}
+
+// NextScheduledRun is the first occurrence of the job's schedule after after,
+// whether or not the job is one-time, delayed by the job's jitter.
+func NextScheduledRun(job *models.Job, after time.Time) (*time.Time, error) {
+	sched, err := JobSchedule(job)
+	if err != nil {
+		return nil, err
+	}
+	return nextRun(job, sched, after)
+}
+
+func nextRun(job *models.Job, sched cron.Schedule, after time.Time) (*time.Time, error) {
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	next := sched.Next(after.In(loc)).UTC()
+	if next.IsZero() {
+		return nil, fmt.Errorf("schedule has no upcoming run")
+	}
+	next = withJitter(job, next)
+	return &next, nil
+}
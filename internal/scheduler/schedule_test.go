@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+func mustTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestNextScheduledRun(t *testing.T) {
+	tests := []struct {
+		name  string
+		job   models.Job
+		after string
+		want  string
+	}{
+		{
+			name:  "five field cron",
+			job:   models.Job{Schedule: "*/15 * * * *", Timezone: "UTC"},
+			after: "2026-03-01T12:07:00Z",
+			want:  "2026-03-01T12:15:00Z",
+		},
+		{
+			name:  "seconds field",
+			job:   models.Job{Schedule: "*/20 * * * * *", Timezone: "UTC"},
+			after: "2026-03-01T12:00:05Z",
+			want:  "2026-03-01T12:00:20Z",
+		},
+		{
+			name:  "cron follows the wall clock across spring forward",
+			job:   models.Job{Schedule: "0 9 * * *", Timezone: "America/New_York"},
+			after: "2026-03-07T15:00:00Z",
+			want:  "2026-03-08T13:00:00Z",
+		},
+		{
+			name:  "cron follows the wall clock across fall back",
+			job:   models.Job{Schedule: "0 9 * * *", Timezone: "America/New_York"},
+			after: "2026-10-31T14:00:00Z",
+			want:  "2026-11-01T14:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		got, err := NextScheduledRun(&tt.job, mustTime(t, tt.after))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := mustTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("%s: NextScheduledRun = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestNextScheduledRunErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		job  models.Job
+	}{
+		{"bad timezone", models.Job{Schedule: "* * * * *", Timezone: "Mars/Olympus"}},
+		{"bad cron", models.Job{Schedule: "61 * * * *", Timezone: "UTC"}},
+	}
+	for _, tt := range tests {
+		if _, err := NextScheduledRun(&tt.job, time.Now()); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{"@every 90m", 90 * time.Minute, false},
+		{"90m", 90 * time.Minute, false},
+		{"  @every 1s ", time.Second, false},
+		{"@every 1h30m", 90 * time.Minute, false},
+		{"500ms", 0, true},
+		{"@every", 0, true},
+		{"*/5 * * * *", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseInterval(tt.schedule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseInterval(%q) error = %v, wantErr %v", tt.schedule, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseInterval(%q) = %s, want %s", tt.schedule, got, tt.want)
+		}
+	}
+}
+
+func TestAnchoredIntervalNext(t *testing.T) {
+	anchor := mustTime(t, "2026-01-01T00:00:00Z")
+	s := anchoredInterval{Anchor: anchor, Every: 90 * time.Minute}
+	tests := []struct {
+		name  string
+		after string
+		want  string
+	}{
+		{"before anchor", "2025-12-31T12:00:00Z", "2026-01-01T00:00:00Z"},
+		{"on anchor", "2026-01-01T00:00:00Z", "2026-01-01T01:30:00Z"},
+		{"between occurrences", "2026-01-01T02:00:00Z", "2026-01-01T03:00:00Z"},
+		{"on an occurrence", "2026-01-01T03:00:00Z", "2026-01-01T04:30:00Z"},
+		{"days later", "2026-01-10T00:10:00Z", "2026-01-10T01:30:00Z"},
+	}
+	for _, tt := range tests {
+		got := s.Next(mustTime(t, tt.after))
+		if want := mustTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("%s: Next = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestFixedDelayNext(t *testing.T) {
+	s := fixedDelay{Delay: 5 * time.Minute}
+	after := mustTime(t, "2026-01-01T10:02:17Z")
+	if got, want := s.Next(after), mustTime(t, "2026-01-01T10:07:17Z"); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
+
+func TestNextScheduledRunKinds(t *testing.T) {
+	created := mustTime(t, "2026-03-01T12:00:00Z")
+	tests := []struct {
+		name  string
+		job   models.Job
+		after string
+		want  string
+	}{
+		{
+			name:  "descriptor",
+			job:   models.Job{Schedule: "@daily", Timezone: "UTC"},
+			after: "2026-03-01T12:00:00Z",
+			want:  "2026-03-02T00:00:00Z",
+		},
+		{
+			name:  "interval keeps absolute time across spring forward",
+			job:   models.Job{Schedule: "@every 24h", ScheduleKind: models.ScheduleInterval, Timezone: "America/New_York", CreatedAt: created},
+			after: "2026-03-08T12:30:00Z",
+			want:  "2026-03-09T12:00:00Z",
+		},
+		{
+			name:  "fixed delay counts from after",
+			job:   models.Job{Schedule: "@every 10m", ScheduleKind: models.ScheduleFixedDelay, Timezone: "UTC"},
+			after: "2026-03-01T12:03:30Z",
+			want:  "2026-03-01T12:13:30Z",
+		},
+	}
+	for _, tt := range tests {
+		got, err := NextScheduledRun(&tt.job, mustTime(t, tt.after))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := mustTime(t, tt.want); !got.Equal(want) {
+			t.Errorf("%s: NextScheduledRun = %s, want %s", tt.name, got, want)
+		}
+	}
+}
+
+func TestNextScheduledRunKindErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		job  models.Job
+	}{
+		{"bad interval", models.Job{Schedule: "soon", ScheduleKind: models.ScheduleInterval, Timezone: "UTC"}},
+		{"unknown kind", models.Job{Schedule: "* * * * *", ScheduleKind: "weekly", Timezone: "UTC"}},
+	}
+	for _, tt := range tests {
+		if _, err := NextScheduledRun(&tt.job, time.Now()); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -5,33 +5,100 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/akhilbisht798/gocrony/internal/cache"
 	"github.com/akhilbisht798/gocrony/internal/db"
 	"github.com/akhilbisht798/gocrony/internal/models"
-	"github.com/robfig/cron/v3"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
+// QUEUE is also the prefix of every other redis key gocrony uses, queue jobs
+// are not allowed to write under it.
 const QUEUE = "jobs"
 
+// PROCESSING_QUEUE holds entries a worker has taken from QUEUE but not yet
+// acknowledged, and INFLIGHT_QUEUE scores each of them by the time it becomes
+// visible again if the worker stops heartbeating.
+const (
+	PROCESSING_QUEUE = "jobs:processing"
+	INFLIGHT_QUEUE   = "jobs:inflight"
+)
+
+// Scheduler runs a pass whenever the earliest next_run comes due, at least
+// every SCHEDULER_TICK_SECONDS, while elector holds the scheduler lease.
 // TODO: save errors and response as logs.
-func Scheduler() {
+func Scheduler(ctx context.Context, elector *Elector) {
 	log.Println("job scheduler started")
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		log.Println("RUNNING AGAIN")
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		if err := getJobsAndSchedule(ctx); err != nil {
-			log.Printf("Error processing schedule jobs: %v", err)
+	sub := cache.Rbd.Subscribe(ctx, WAKE_CHANNEL)
+	defer sub.Close()
+	wake := sub.Channel()
+
+	wait := plannedWait(ctx, elector)
+	deadline := time.Now().Add(wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("job scheduler stopped")
+			return
+		case <-wake:
+			// Something moved a next_run. A wake only brings the pass
+			// forward, otherwise a steady stream of them would keep pushing
+			// it back and nothing would ever be enqueued.
+			if !elector.IsLeader() {
+				continue
+			}
+			if next := time.Now().Add(nextWait(ctx)); next.Before(deadline) {
+				deadline = next
+				timer.Reset(time.Until(deadline))
+			}
+			continue
+		case <-timer.C:
+		}
+		if elector.IsLeader() {
+			runPass(elector)
 		}
-		cancel()
+		wait = plannedWait(ctx, elector)
+		deadline = time.Now().Add(wait)
+		timer.Reset(wait)
+	}
+}
+
+// plannedWait is the sleep until the next pass, followers only check back
+// every tick in case they become leader.
+func plannedWait(ctx context.Context, elector *Elector) time.Duration {
+	if elector.IsLeader() {
+		return nextWait(ctx)
 	}
+	return schedulerTick()
 }
 
-func getJobsAndSchedule(ctx context.Context) error {
+// runPass enqueues everything that is due.
+func runPass(elector *Elector) {
+	// A pass is not tied to ctx so shutdown never leaves a job pushed to
+	// redis without its status being set to queued.
+	passCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := getJobsAndSchedule(passCtx, elector); err != nil {
+		log.Printf("Error processing schedule jobs: %v", err)
+	}
+	if err := getWorkflowsAndSchedule(passCtx); err != nil {
+		log.Printf("Error processing schedule workflows: %v", err)
+	}
+	if err := getDeliveriesAndSchedule(passCtx); err != nil {
+		log.Printf("Error processing webhook deliveries: %v", err)
+	}
+	cancel()
+}
+
+// errLostLease stops a pass once this instance is no longer the leader.
+var errLostLease = errors.New("scheduler lease lost")
+
+func getJobsAndSchedule(ctx context.Context, elector *Elector) error {
 	var jobs []models.Job
 	now := time.Now().UTC()
 
@@ -50,7 +117,10 @@ func getJobsAndSchedule(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			if err := processJobs(ctx, &job); err != nil {
+			if err := processJobs(ctx, elector, &job); err != nil {
+				if errors.Is(err, errLostLease) {
+					return err
+				}
 				log.Printf("Error Processing job %s: %v", job.ID, err.Error())
 				continue
 			}
@@ -59,26 +129,99 @@ func getJobsAndSchedule(ctx context.Context) error {
 	return nil
 }
 
-func processJobs(ctx context.Context, job *models.Job) error {
-	// Queue it.
-	err := enqueueJob(ctx, job.ID.String())
-	if err != nil {
-		log.Println("Error pushing to queue: ", err.Error())
-		return err
+// claimJob applies updates only while the job still has the status and
+// next_run this pass read it with. A pass racing another one, e.g. on an
+// instance that lost the lease halfway, then can't act on it twice.
+func claimJob(job *models.Job, updates map[string]any) (bool, error) {
+	tx := db.DB.Model(&models.Job{}).
+		Where("id = ? AND COALESCE(status, '') = ? AND next_run = ?", job.ID, job.Status, job.NextRun).
+		Updates(updates)
+	return tx.RowsAffected == 1, tx.Error
+}
+
+func processJobs(ctx context.Context, elector *Elector, job *models.Job) error {
+	// Retries fire at their backoff time, only schedule occurrences go
+	// through the misfire policy.
+	if job.Status != models.StatusFailed {
+		plan, err := planMisfire(job, time.Now().UTC())
+		if err != nil {
+			return fmt.Errorf("Error: unable to plan missed runs %w", err)
+		}
+		runAt := plan.RunAt
+		if runAt == nil {
+			runAt = plan.NextRun
+		}
+		// next_run is what the worker reports as the scheduled time, so it
+		// has to name the occurrence being run.
+		if runAt == nil || !runAt.Equal(*job.NextRun) {
+			claimed, err := claimJob(job, map[string]any{"next_run": runAt})
+			if err != nil {
+				return fmt.Errorf("Error: unable to update next run of the job %w", err)
+			}
+			if !claimed {
+				return nil
+			}
+			job.NextRun = runAt
+		}
+		logSkippedRuns(job, plan)
+		if plan.RunAt == nil {
+			// A one-time job that missed its slot has nothing left to run.
+			// last_run is set anyway, the reaper counts retention from it.
+			if job.OneTime() {
+				_, err := claimJob(job, map[string]any{
+					"status":   models.StatusCompleted,
+					"last_run": time.Now().UTC(),
+				})
+				return err
+			}
+			return nil
+		}
 	}
-	// Status to be queued.
-	updates := map[string]any{
-		"status": models.StatusQueued,
+
+	// The job is marked queued before it is pushed, so it is only pushed by
+	// the pass that won the update, and only while this instance still
+	// holds the lease.
+	if !elector.IsLeader() {
+		return errLostLease
 	}
-	log.Println("Enqueued job and updating it.")
-	err = db.DB.Model(job).Updates(updates).Error
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	claimed, err := claimJob(job, map[string]any{
+		"status":    models.StatusQueued,
+		"queued_at": time.Now().UTC(),
+	})
 	if err != nil {
 		return fmt.Errorf("Error: unable to update status of the job %w", err)
 	}
-	log.Println("updated success.")
+	if !claimed {
+		return nil
+	}
+	if err := enqueueJob(ctx, job.ID.String()); err != nil {
+		log.Println("Error pushing to queue: ", err.Error())
+		db.DB.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, models.StatusQueued).Update("status", job.Status)
+		return err
+	}
+	log.Printf("Enqueued job %s", job.ID)
 	return nil
 }
 
+// Enqueue pushes an entry for the workers: a job id or a prefixed entry such
+// as WORKFLOW_PREFIX or WEBHOOK_PREFIX followed by an id.
+func Enqueue(ctx context.Context, entry string) error {
+	return enqueueJob(ctx, entry)
+}
+
+// DELIVERY_SEPARATOR splits a queue entry from the token that makes each
+// push unique, so two copies of an entry have their own visibility deadline.
+const DELIVERY_SEPARATOR = "#"
+
+// EntryName strips the delivery token from a raw queue entry.
+func EntryName(raw string) string {
+	entry, _, _ := strings.Cut(raw, DELIVERY_SEPARATOR)
+	return entry
+}
+
 func enqueueJob(ctx context.Context, jobId string) error {
 	if cache.Rbd == nil {
 		return errors.New("redis client not initialized.")
@@ -86,19 +229,50 @@ func enqueueJob(ctx context.Context, jobId string) error {
 	if jobId == "" {
 		return errors.New("Job Id cannot be empty")
 	}
-	return cache.Rbd.LPush(ctx, QUEUE, jobId).Err()
+	return cache.Rbd.LPush(ctx, QUEUE, jobId+DELIVERY_SEPARATOR+uuid.NewString()).Err()
+}
+
+// removeQueuedScript drops every delivery of an entry still waiting in QUEUE.
+var removeQueuedScript = redis.NewScript(`
+local removed = 0
+local prefix = ARGV[1] .. ARGV[2]
+for _, item in ipairs(redis.call('LRANGE', KEYS[1], 0, -1)) do
+	if item == ARGV[1] or string.sub(item, 1, #prefix) == prefix then
+		removed = removed + redis.call('LREM', KEYS[1], 0, item)
+	end
+end
+return removed
+`)
+
+func removeQueued(ctx context.Context, entry string) error {
+	return removeQueuedScript.Run(ctx, cache.Rbd, []string{QUEUE}, entry, DELIVERY_SEPARATOR).Err()
+}
+
+// NextOccurrence is the job's next_run after a run that fired at after, nil
+// for one-time jobs which never run again.
+func NextOccurrence(job *models.Job, after time.Time) (*time.Time, error) {
+	if job.OneTime() {
+		return nil, nil
+	}
+	return NextScheduledRun(job, after)
 }
 
 func GetNextRun(schedule string, tzone string) (*time.Time, error) {
+	return GetNextRunAfter(schedule, tzone, time.Now())
+}
+
+// GetNextRunAfter is GetNextRun counted from after instead of now, it lets
+// catch-up runs walk through missed occurrences one by one.
+func GetNextRunAfter(schedule string, tzone string, after time.Time) (*time.Time, error) {
 	loc, err := time.LoadLocation(tzone)
 	if err != nil {
 		return &time.Time{}, err
 	}
-	scheduler, err := cron.ParseStandard(schedule)
-	now := time.Now().In(loc)
+	scheduler, err := ParseSchedule(schedule)
+	after = after.In(loc)
 	if err != nil {
-		return &now, err
+		return &after, err
 	}
-	nextRun := scheduler.Next(now).UTC()
+	nextRun := scheduler.Next(after).UTC()
 	return &nextRun, nil
 }
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+type fieldRange struct {
+	min, max int
+}
+
+// Ranges H picks from for each cron field. Day of month stops at 28 so a
+// hashed day exists in every month.
+var (
+	secondRange = fieldRange{0, 59}
+	fiveFields  = []fieldRange{{0, 59}, {0, 23}, {1, 28}, {1, 12}, {0, 6}}
+)
+
+// ExpandHash replaces Jenkins style H tokens with values derived from seed,
+// so jobs sharing a schedule like "H * * * *" each get their own stable
+// minute. It understands H, H/step, H(a-b) and H(a-b)/step in any field of a
+// five or six field expression; descriptors are returned unchanged.
+func ExpandHash(schedule string, seed uuid.UUID) (string, error) {
+	if !strings.Contains(schedule, "H") || strings.HasPrefix(strings.TrimSpace(schedule), "@") {
+		return schedule, nil
+	}
+	fields := strings.Fields(schedule)
+	ranges := fiveFields
+	switch len(fields) {
+	case 5:
+	case 6:
+		ranges = append([]fieldRange{secondRange}, fiveFields...)
+	default:
+		return "", fmt.Errorf("expected 5 to 6 fields, found %d: %s", len(fields), schedule)
+	}
+
+	for i, field := range fields {
+		parts := strings.Split(field, ",")
+		for j, part := range parts {
+			if !strings.HasPrefix(part, "H") {
+				continue
+			}
+			expanded, err := expandHashToken(part, ranges[i], hashField(seed, i))
+			if err != nil {
+				return "", err
+			}
+			parts[j] = expanded
+		}
+		fields[i] = strings.Join(parts, ",")
+	}
+	return strings.Join(fields, " "), nil
+}
+
+func expandHashToken(token string, r fieldRange, hash uint32) (string, error) {
+	rest := strings.TrimPrefix(token, "H")
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated range in %q", token)
+		}
+		bounds := strings.SplitN(rest[1:end], "-", 2)
+		if len(bounds) != 2 {
+			return "", fmt.Errorf("invalid range in %q", token)
+		}
+		lo, err1 := strconv.Atoi(bounds[0])
+		hi, err2 := strconv.Atoi(bounds[1])
+		if err1 != nil || err2 != nil || lo < r.min || hi > r.max || lo > hi {
+			return "", fmt.Errorf("invalid range in %q, must be within %d-%d", token, r.min, r.max)
+		}
+		r = fieldRange{lo, hi}
+		rest = rest[end+1:]
+	}
+
+	if rest == "" {
+		return strconv.Itoa(r.min + int(hash%uint32(r.max-r.min+1))), nil
+	}
+	if !strings.HasPrefix(rest, "/") {
+		return "", fmt.Errorf("invalid hash expression %q", token)
+	}
+	step, err := strconv.Atoi(rest[1:])
+	if err != nil || step < 1 {
+		return "", fmt.Errorf("invalid step in %q", token)
+	}
+	// The hash picks where in the first step the sequence starts.
+	start := r.min + int(hash%uint32(min(step, r.max-r.min+1)))
+	return fmt.Sprintf("%d-%d/%d", start, r.max, step), nil
+}
+
+func hashField(seed uuid.UUID, field int) uint32 {
+	h := fnv.New32a()
+	// The field goes first so it is mixed through the whole seed.
+	h.Write([]byte{byte(field)})
+	h.Write(seed[:])
+	return h.Sum32()
+}
+
+// MAX_PERIOD_SAMPLES bounds how many occurrences ValidateJitter compares.
+const MAX_PERIOD_SAMPLES = 1000
+
+// ValidateJitter rejects a jitter window at least as long as the shortest gap
+// between two occurrences over the next year. A run delayed that far would
+// land past the following occurrence.
+func ValidateJitter(job *models.Job) error {
+	if job.JitterSeconds <= 0 || job.RunAt != nil {
+		return nil
+	}
+	jitter := time.Duration(job.JitterSeconds) * time.Second
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		return err
+	}
+	sched, err := baseSchedule(job)
+	if err != nil {
+		return err
+	}
+	prev := sched.Next(time.Now().In(loc))
+	end := prev.AddDate(1, 0, 0)
+	for i := 0; i < MAX_PERIOD_SAMPLES && !prev.IsZero() && prev.Before(end); i++ {
+		next := sched.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if gap := next.Sub(prev); gap <= jitter {
+			return fmt.Errorf("jitter_seconds must be shorter than the %s between runs", gap)
+		}
+		prev = next
+	}
+	return nil
+}
+
+// withJitter delays t by a random part of the job's jitter window.
+func withJitter(job *models.Job, t time.Time) time.Time {
+	if job.JitterSeconds <= 0 {
+		return t
+	}
+	return t.Add(time.Duration(rand.Int64N(int64(job.JitterSeconds) * int64(time.Second))))
+}
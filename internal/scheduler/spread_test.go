@@ -0,0 +1,197 @@
+package scheduler
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+var testSeed = uuid.MustParse("7d3c1a52-4f0e-4a8b-9c61-2b5e8f9d0a17")
+
+func TestExpandHashUnchanged(t *testing.T) {
+	for _, schedule := range []string{"*/5 * * * *", "0 9 * * 1-5", "@hourly", "@every 1h"} {
+		got, err := ExpandHash(schedule, testSeed)
+		if err != nil || got != schedule {
+			t.Errorf("ExpandHash(%q) = %q, %v, want it unchanged", schedule, got, err)
+		}
+	}
+}
+
+func TestExpandHashRanges(t *testing.T) {
+	tests := []struct {
+		schedule string
+		field    int
+		min, max int
+	}{
+		{"H * * * *", 0, 0, 59},
+		{"0 H * * *", 1, 0, 23},
+		{"0 0 H * *", 2, 1, 28},
+		{"0 0 1 H *", 3, 1, 12},
+		{"0 0 * * H", 4, 0, 6},
+		{"H * * * * *", 0, 0, 59},
+		{"0 H(9-17) * * *", 1, 9, 17},
+		{"H(30-30) * * * *", 0, 30, 30},
+	}
+	for _, tt := range tests {
+		// Every seed has to land inside the field's range.
+		for i := 0; i < 200; i++ {
+			seed := uuid.New()
+			got, err := ExpandHash(tt.schedule, seed)
+			if err != nil {
+				t.Fatalf("ExpandHash(%q): %v", tt.schedule, err)
+			}
+			value, err := strconv.Atoi(strings.Fields(got)[tt.field])
+			if err != nil || value < tt.min || value > tt.max {
+				t.Fatalf("ExpandHash(%q, %s) = %q, field %d outside %d-%d", tt.schedule, seed, got, tt.field, tt.min, tt.max)
+			}
+			if _, err := ParseSchedule(got); err != nil {
+				t.Fatalf("ExpandHash(%q) = %q does not parse: %v", tt.schedule, got, err)
+			}
+		}
+	}
+}
+
+func TestExpandHashSteps(t *testing.T) {
+	// The hash picks a start in [low, below), the sequence runs to max.
+	tests := []struct {
+		schedule   string
+		field      int
+		low, below int
+		rest       string
+	}{
+		{"H/15 * * * *", 0, 0, 15, "59/15"},
+		{"0 H/6 * * *", 1, 0, 6, "23/6"},
+		{"H(0-29)/10 * * * *", 0, 0, 10, "29/10"},
+		{"H(5-20)/60 * * * *", 0, 5, 21, "20/60"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 200; i++ {
+			got, err := ExpandHash(tt.schedule, uuid.New())
+			if err != nil {
+				t.Fatalf("ExpandHash(%q): %v", tt.schedule, err)
+			}
+			start, rest, _ := strings.Cut(strings.Fields(got)[tt.field], "-")
+			first, err := strconv.Atoi(start)
+			if err != nil || first < tt.low || first >= tt.below || rest != tt.rest {
+				t.Fatalf("ExpandHash(%q) = %q", tt.schedule, got)
+			}
+			if _, err := ParseSchedule(got); err != nil {
+				t.Fatalf("ExpandHash(%q) = %q does not parse: %v", tt.schedule, got, err)
+			}
+		}
+	}
+}
+
+func TestExpandHashIsStable(t *testing.T) {
+	schedule := "H H(8-18) * * 1-5"
+	first, err := ExpandHash(schedule, testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if got, _ := ExpandHash(schedule, testSeed); got != first {
+			t.Fatalf("ExpandHash is not stable: %q then %q", first, got)
+		}
+	}
+}
+
+func TestExpandHashList(t *testing.T) {
+	got, err := ExpandHash("0,H * * * *", testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "0,") {
+		t.Errorf("ExpandHash kept %q, want the literal 0 kept", got)
+	}
+}
+
+func TestExpandHashErrors(t *testing.T) {
+	for _, schedule := range []string{
+		"H * * *",
+		"H * * * * * *",
+		"H(5-3) * * * *",
+		"H(0-60) * * * *",
+		"H(0-10 * * * *",
+		"H(a-b) * * * *",
+		"H/0 * * * *",
+		"H/x * * * *",
+		"Hx * * * *",
+	} {
+		if got, err := ExpandHash(schedule, testSeed); err == nil {
+			t.Errorf("ExpandHash(%q) = %q, expected an error", schedule, got)
+		}
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	at := mustTime(t, "2026-01-01T10:00:00Z")
+	if got := withJitter(&models.Job{}, at); !got.Equal(at) {
+		t.Errorf("withJitter without a window = %s, want %s", got, at)
+	}
+	job := &models.Job{JitterSeconds: 30}
+	for i := 0; i < 200; i++ {
+		got := withJitter(job, at)
+		if got.Before(at) || !got.Before(at.Add(30*time.Second)) {
+			t.Fatalf("withJitter = %s, want within 30s after %s", got, at)
+		}
+	}
+}
+
+func TestValidateJitter(t *testing.T) {
+	runAt := time.Now()
+	tests := []struct {
+		name    string
+		job     models.Job
+		wantErr bool
+	}{
+		{"no jitter", models.Job{Schedule: "* * * * * *", Timezone: "UTC"}, false},
+		{"shorter than the period", models.Job{Schedule: "0 * * * *", JitterSeconds: 600, Timezone: "UTC"}, false},
+		{"as long as the period", models.Job{Schedule: "*/15 * * * * *", JitterSeconds: 15, Timezone: "UTC"}, true},
+		{"longer than the period", models.Job{Schedule: "*/15 * * * * *", JitterSeconds: 60, Timezone: "UTC"}, true},
+		{"shortest gap of an uneven schedule", models.Job{Schedule: "0 0,1 * * *", JitterSeconds: 3600, Timezone: "UTC"}, true},
+		{"fixed delay", models.Job{Schedule: "5m", ScheduleKind: models.ScheduleFixedDelay, JitterSeconds: 300, Timezone: "UTC"}, true},
+		{"interval", models.Job{Schedule: "@every 10m", ScheduleKind: models.ScheduleInterval, JitterSeconds: 60, Timezone: "UTC"}, false},
+		{"run_at jobs have no period", models.Job{RunAt: &runAt, JitterSeconds: 3600, Timezone: "UTC"}, false},
+	}
+	for _, tt := range tests {
+		err := ValidateJitter(&tt.job)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateJitter error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPlanMisfireHashedSchedule(t *testing.T) {
+	job := models.Job{
+		ID:        testSeed,
+		Schedule:  "H * * * *",
+		Timezone:  "UTC",
+		Recurring: true,
+	}
+	expanded, err := ExpandHash(job.Schedule, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched, err := ParseSchedule(expanded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := sched.Next(mustTime(t, "2026-01-01T00:00:00Z"))
+	job.NextRun = timePtr(first)
+
+	plan, err := planMisfire(&job, first.Add(2*time.Hour+time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := first.Add(2 * time.Hour)
+	if plan.RunAt == nil || !plan.RunAt.Equal(want) {
+		t.Fatalf("RunAt = %v, want %s", plan.RunAt, want)
+	}
+	if len(plan.Skipped) != 2 {
+		t.Errorf("Skipped %d occurrences, want 2", len(plan.Skipped))
+	}
+}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/config"
+	"github.com/akhilbisht798/gocrony/internal/cache"
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// DEFAULT_SCHEDULER_TICK is the longest the scheduler sleeps between
+	// passes, whatever the upcoming next_run values say.
+	DEFAULT_SCHEDULER_TICK = 1 * time.Minute
+	// OVERDUE_WAIT spaces passes out while something stays due, e.g. a job
+	// whose enqueue keeps failing, so the loop doesn't spin.
+	OVERDUE_WAIT = 1 * time.Second
+	// WAKE_CHANNEL tells the scheduler a next_run changed so it can wake up
+	// earlier than it planned.
+	WAKE_CHANNEL = "jobs:wake"
+)
+
+// cronParser accepts standard five field expressions, an optional leading
+// seconds field and descriptors such as @hourly.
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+func ParseSchedule(schedule string) (cron.Schedule, error) {
+	return cronParser.Parse(schedule)
+}
+
+// schedulerTick is read from SCHEDULER_TICK_SECONDS.
+func schedulerTick() time.Duration {
+	seconds := config.GetEnvInt("SCHEDULER_TICK_SECONDS", 0)
+	if seconds <= 0 {
+		return DEFAULT_SCHEDULER_TICK
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// nextWait is how long the scheduler may sleep: until the earliest next_run
+// of anything it would pick up, bounded by the tick.
+func nextWait(ctx context.Context) time.Duration {
+	tick := schedulerTick()
+	var earliest []*time.Time
+	queries := []struct {
+		model  any
+		column string
+		where  string
+		args   []any
+	}{
+		{&models.Job{}, "next_run", "enabled = ? AND (status IS NULL OR status = '' OR status = ? OR status = ?)",
+			[]any{true, models.StatusPending, models.StatusFailed}},
+		{&models.Workflow{}, "next_run", "enabled = ?", []any{true}},
+		{&models.WebhookDelivery{}, "next_attempt_at", "status = ?", []any{models.DeliveryPending}},
+	}
+	for _, q := range queries {
+		var next *time.Time
+		err := db.DB.WithContext(ctx).Model(q.model).Where(q.where, q.args...).
+			Select("MIN(" + q.column + ")").Scan(&next).Error
+		if err != nil {
+			log.Printf("Error finding next wake up: %v", err)
+			return tick
+		}
+		earliest = append(earliest, next)
+	}
+
+	wait := tick
+	for _, next := range earliest {
+		if next == nil {
+			continue
+		}
+		until := time.Until(*next)
+		if until <= 0 {
+			return OVERDUE_WAIT
+		}
+		wait = min(wait, until)
+	}
+	return wait
+}
+
+// Wake asks the scheduler, on whichever instance leads, to recompute its
+// sleep. Callers that move a next_run earlier use it so sub-minute schedules
+// fire on time.
+func Wake(ctx context.Context) {
+	if cache.Rbd == nil {
+		return
+	}
+	if err := cache.Rbd.Publish(ctx, WAKE_CHANNEL, "").Err(); err != nil {
+		log.Printf("Error waking scheduler: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+// WEBHOOK_PREFIX marks queue entries that carry a webhook delivery id.
+const WEBHOOK_PREFIX = "webhook:"
+
+// getDeliveriesAndSchedule enqueues webhook deliveries whose retry is due.
+func getDeliveriesAndSchedule(ctx context.Context) error {
+	var deliveries []models.WebhookDelivery
+	now := time.Now().UTC()
+	err := db.DB.Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).Find(&deliveries).Error
+	if err != nil {
+		return fmt.Errorf("Error: failed to fetch webhook deliveries %w", err)
+	}
+	for _, delivery := range deliveries {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		tx := db.DB.Model(&delivery).Where("status = ?", models.DeliveryPending).Update("status", models.DeliveryQueued)
+		if tx.Error != nil || tx.RowsAffected == 0 {
+			continue
+		}
+		if err := enqueueJob(ctx, WEBHOOK_PREFIX+delivery.ID.String()); err != nil {
+			log.Printf("Error enqueueing webhook delivery %s: %v", delivery.ID, err)
+			db.DB.Model(&delivery).Update("status", models.DeliveryPending)
+		}
+	}
+	return nil
+}
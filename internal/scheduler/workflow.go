@@ -0,0 +1,225 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+// WORKFLOW_PREFIX marks queue entries that carry a workflow node run id
+// instead of a job id.
+const WORKFLOW_PREFIX = "workflow:"
+
+// ValidateWorkflow checks that node keys are unique, that every dependency
+// names a node of the workflow and that the dependencies form no cycle.
+func ValidateWorkflow(nodes []models.WorkflowNode) error {
+	byKey := make(map[string]*models.WorkflowNode, len(nodes))
+	for i := range nodes {
+		if _, ok := byKey[nodes[i].Key]; ok {
+			return fmt.Errorf("duplicate node key %q", nodes[i].Key)
+		}
+		byKey[nodes[i].Key] = &nodes[i]
+	}
+
+	// Kahn's algorithm: repeatedly remove nodes whose dependencies are all
+	// removed. Anything left over sits on a cycle.
+	pending := make(map[string]int, len(nodes))
+	dependents := make(map[string][]string)
+	var ready []string
+	for _, node := range nodes {
+		for _, dep := range node.DependsOn {
+			if _, ok := byKey[dep.Node]; !ok {
+				return fmt.Errorf("node %q depends on unknown node %q", node.Key, dep.Node)
+			}
+			dependents[dep.Node] = append(dependents[dep.Node], node.Key)
+		}
+		pending[node.Key] = len(node.DependsOn)
+		if len(node.DependsOn) == 0 {
+			ready = append(ready, node.Key)
+		}
+	}
+	if len(ready) == 0 {
+		return fmt.Errorf("workflow has no root node")
+	}
+	visited := 0
+	for len(ready) > 0 {
+		key := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, next := range dependents[key] {
+			pending[next]--
+			if pending[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if visited != len(nodes) {
+		for key, count := range pending {
+			if count > 0 {
+				return fmt.Errorf("dependency cycle involving node %q", key)
+			}
+		}
+	}
+	return nil
+}
+
+func getWorkflowsAndSchedule(ctx context.Context) error {
+	var workflows []models.Workflow
+	now := time.Now().UTC()
+	if err := db.DB.Where("next_run <= ? AND enabled = ?", now, true).Find(&workflows).Error; err != nil {
+		return fmt.Errorf("Error: failed to fetch schedule workflows %w", err)
+	}
+	for _, workflow := range workflows {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		// Move next_run first so a failure below can't start the same slot
+		// twice on the next pass.
+		nextRun, err := GetNextRun(workflow.Schedule, workflow.Timezone)
+		if err != nil {
+			log.Printf("Error computing next run of workflow %s: %v", workflow.ID, err)
+			continue
+		}
+		if err := db.DB.Model(&workflow).Update("next_run", nextRun).Error; err != nil {
+			log.Printf("Error updating workflow %s: %v", workflow.ID, err)
+			continue
+		}
+		if _, err := StartWorkflowRun(ctx, &workflow); err != nil {
+			log.Printf("Error starting workflow %s: %v", workflow.ID, err)
+		}
+	}
+	return nil
+}
+
+// StartWorkflowRun records a new run of workflow and enqueues its root nodes.
+func StartWorkflowRun(ctx context.Context, workflow *models.Workflow) (*models.WorkflowRun, error) {
+	var nodes []models.WorkflowNode
+	if err := db.DB.Where("workflow_id = ?", workflow.ID).Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	run := models.WorkflowRun{
+		WorkflowID: workflow.ID,
+		Status:     models.WorkflowRunning,
+		StartedAt:  time.Now().UTC(),
+	}
+	for _, node := range nodes {
+		run.Nodes = append(run.Nodes, models.WorkflowNodeRun{
+			NodeID:  node.ID,
+			NodeKey: node.Key,
+			JobID:   node.JobID,
+			Status:  models.NodeWaiting,
+		})
+	}
+	if err := db.DB.Create(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, AdvanceWorkflowRun(ctx, run.ID.String())
+}
+
+// AdvanceWorkflowRun enqueues every waiting node whose dependencies are met,
+// skips the ones that can no longer run and closes the run once every node is
+// done. It is called whenever a node finishes and is safe to call
+// concurrently: each node changes state through a conditional update.
+func AdvanceWorkflowRun(ctx context.Context, runId string) error {
+	var run models.WorkflowRun
+	if err := db.DB.Preload("Nodes").Where("id = ?", runId).First(&run).Error; err != nil {
+		return err
+	}
+	if run.Status != models.WorkflowRunning {
+		return nil
+	}
+	var nodes []models.WorkflowNode
+	if err := db.DB.Where("workflow_id = ?", run.WorkflowID).Find(&nodes).Error; err != nil {
+		return err
+	}
+	dependsOn := make(map[string][]models.WorkflowDependency, len(nodes))
+	for _, node := range nodes {
+		dependsOn[node.Key] = node.DependsOn
+	}
+	status := make(map[string]models.NodeRunStatus, len(run.Nodes))
+	for _, nodeRun := range run.Nodes {
+		status[nodeRun.NodeKey] = nodeRun.Status
+	}
+
+	// Skipping a node can settle its dependents, so repeat until stable.
+	for changed := true; changed; {
+		changed = false
+		for i := range run.Nodes {
+			nodeRun := &run.Nodes[i]
+			if status[nodeRun.NodeKey] != models.NodeWaiting {
+				continue
+			}
+			decided, runnable := dependenciesMet(dependsOn[nodeRun.NodeKey], status)
+			if !decided {
+				continue
+			}
+			next := models.NodeSkipped
+			if runnable {
+				next = models.NodeQueued
+			}
+			updates := map[string]any{"status": next}
+			if next == models.NodeSkipped {
+				updates["finished_at"] = time.Now().UTC()
+			}
+			tx := db.DB.Model(&models.WorkflowNodeRun{}).
+				Where("id = ? AND status = ?", nodeRun.ID, models.NodeWaiting).
+				Updates(updates)
+			if tx.Error != nil {
+				return tx.Error
+			}
+			status[nodeRun.NodeKey] = next
+			changed = true
+			if tx.RowsAffected == 0 || next != models.NodeQueued {
+				continue
+			}
+			if err := enqueueJob(ctx, WORKFLOW_PREFIX+nodeRun.ID.String()); err != nil {
+				return fmt.Errorf("Error: unable to enqueue node %s: %w", nodeRun.NodeKey, err)
+			}
+		}
+	}
+
+	finalStatus := models.WorkflowSucceeded
+	for _, s := range status {
+		switch s {
+		case models.NodeWaiting, models.NodeQueued, models.NodeRunning:
+			return nil
+		case models.NodeFailed:
+			finalStatus = models.WorkflowFailed
+		}
+	}
+	return db.DB.Model(&models.WorkflowRun{}).
+		Where("id = ? AND status = ?", run.ID, models.WorkflowRunning).
+		Updates(map[string]any{
+			"status":      finalStatus,
+			"finished_at": time.Now().UTC(),
+		}).Error
+}
+
+// dependenciesMet reports whether every upstream node has finished and, if
+// so, whether all conditions hold. A skipped upstream only satisfies
+// "always".
+func dependenciesMet(deps []models.WorkflowDependency, status map[string]models.NodeRunStatus) (decided bool, runnable bool) {
+	runnable = true
+	for _, dep := range deps {
+		upstream := status[dep.Node]
+		switch upstream {
+		case models.NodeSucceeded, models.NodeFailed, models.NodeSkipped:
+		default:
+			return false, false
+		}
+		switch dep.Condition {
+		case models.ConditionAlways:
+		case models.ConditionFailure:
+			runnable = runnable && upstream == models.NodeFailed
+		default:
+			runnable = runnable && upstream == models.NodeSucceeded
+		}
+	}
+	return true, runnable
+}
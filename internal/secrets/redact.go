@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+const REDACTED = "[REDACTED]"
+
+type redactionKey struct{}
+
+// WithRedaction returns a context carrying secret values that Redact must hide.
+func WithRedaction(ctx context.Context, values []string) context.Context {
+	if len(values) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, redactionKey{}, values)
+}
+
+// Redact replaces every secret value attached to ctx with "[REDACTED]". Shell
+// and SQL results are JSON encoded before they are logged, so the escaped
+// forms of each value are replaced too.
+func Redact(ctx context.Context, s string) string {
+	values, _ := ctx.Value(redactionKey{}).([]string)
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, value, REDACTED)
+		for _, escaped := range jsonEscaped(value) {
+			if escaped != value {
+				s = strings.ReplaceAll(s, escaped, REDACTED)
+			}
+		}
+	}
+	return s
+}
+
+// jsonEscaped is value as it appears inside a JSON string, with and without
+// HTML escaping.
+func jsonEscaped(value string) []string {
+	var forms []string
+	for _, escapeHTML := range []bool{true, false} {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(escapeHTML)
+		if err := enc.Encode(value); err != nil {
+			continue
+		}
+		quoted := strings.TrimSpace(buf.String())
+		forms = append(forms, quoted[1:len(quoted)-1])
+	}
+	return forms
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// RedactError hides the secret values in err's message, e.g. a resolved URL
+// inside a request error, while keeping it unwrappable.
+func RedactError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := Redact(ctx, err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
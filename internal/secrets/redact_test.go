@@ -0,0 +1,62 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		in     string
+		want   string
+	}{
+		{"nothing to redact", nil, "token=abc", "token=abc"},
+		{"plain value", []string{"abc"}, "token=abc&again=abc", "token=[REDACTED]&again=[REDACTED]"},
+		{"empty values are ignored", []string{""}, "token=abc", "token=abc"},
+		{"several values", []string{"abc", "xyz"}, "abc:xyz", "[REDACTED]:[REDACTED]"},
+		{"quotes escaped in JSON", []string{`pa"ss`}, `{"password":"pa\"ss"}`, `{"password":"[REDACTED]"}`},
+		{"newline escaped in JSON", []string{"line1\nline2"}, `{"key":"line1\nline2"}`, `{"key":"[REDACTED]"}`},
+		{"HTML escaped in JSON", []string{"a<b>&c"}, `{"key":"a<b>&c"}`, `{"key":"[REDACTED]"}`},
+		{"HTML not escaped in JSON", []string{`a<b>"c`}, `{"key":"a<b>\"c"}`, `{"key":"[REDACTED]"}`},
+	}
+	for _, tt := range tests {
+		ctx := WithRedaction(context.Background(), tt.values)
+		if got := Redact(ctx, tt.in); got != tt.want {
+			t.Errorf("%s: Redact(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactEncodedOutput(t *testing.T) {
+	secret := "p<a>s\"s\\w&rd\t"
+	ctx := WithRedaction(context.Background(), []string{secret})
+	out, err := json.Marshal(map[string]string{"stdout": "logged in with " + secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Redact(ctx, string(out)), `{"stdout":"logged in with [REDACTED]"}`; got != want {
+		t.Errorf("Redact = %q, want %q", got, want)
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	ctx := WithRedaction(context.Background(), []string{"abc"})
+	if RedactError(ctx, nil) != nil {
+		t.Error("RedactError(nil) is not nil")
+	}
+	if err := RedactError(ctx, io.EOF); err != io.EOF {
+		t.Errorf("RedactError wrapped an error without secrets: %v", err)
+	}
+	err := RedactError(ctx, errors.Join(io.ErrUnexpectedEOF, errors.New("GET https://x?token=abc")))
+	if err.Error() != "unexpected EOF\nGET https://x?token=[REDACTED]" {
+		t.Errorf("RedactError = %q", err)
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("RedactError lost the wrapped error")
+	}
+}
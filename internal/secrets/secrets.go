@@ -0,0 +1,127 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/akhilbisht798/gocrony/config"
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+var ErrNotConfigured = errors.New("secrets store not configured, set SECRETS_MASTER_KEY")
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// masterKey reads SECRETS_MASTER_KEY, a base64 encoded 32 byte AES-256 key.
+func masterKey() ([]byte, error) {
+	encoded := config.GetEnv("SECRETS_MASTER_KEY", "")
+	if encoded == "" {
+		return nil, ErrNotConfigured
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%w: SECRETS_MASTER_KEY must be 32 bytes, base64 encoded", ErrNotConfigured)
+	}
+	return key, nil
+}
+
+// CheckConfig reports whether SECRETS_MASTER_KEY holds a usable key.
+func CheckConfig() error {
+	_, err := masterKey()
+	return err
+}
+
+func newGCM() (cipher.AEAD, error) {
+	key, err := masterKey()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals value with AES-GCM. The secret's owner and name are bound as
+// additional data so a ciphertext can't be copied onto another secret.
+func Encrypt(userID uuid.UUID, name string, value string) (ciphertext []byte, nonce []byte, err error) {
+	return seal(additionalData(userID, name), value)
+}
+
+func Decrypt(secret *models.Secret) (string, error) {
+	plaintext, err := open(additionalData(secret.UserID, secret.Name), secret.Ciphertext, secret.Nonce)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt secret %q: %w", secret.Name, err)
+	}
+	return plaintext, nil
+}
+
+// EncryptWebhookSecret seals a webhook signing secret, bound to the webhook's
+// id.
+func EncryptWebhookSecret(webhookID uuid.UUID, value string) (ciphertext []byte, nonce []byte, err error) {
+	return seal(webhookData(webhookID), value)
+}
+
+func DecryptWebhookSecret(webhook *models.Webhook) (string, error) {
+	plaintext, err := open(webhookData(webhook.ID), webhook.SecretCiphertext, webhook.SecretNonce)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt secret of webhook %s: %w", webhook.ID, err)
+	}
+	return plaintext, nil
+}
+
+func seal(data []byte, value string) (ciphertext []byte, nonce []byte, err error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return gcm.Seal(nil, nonce, []byte(value), data), nonce, nil
+}
+
+func open(data []byte, ciphertext []byte, nonce []byte) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return "", errors.New("invalid nonce")
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func additionalData(userID uuid.UUID, name string) []byte {
+	return []byte(userID.String() + "/" + name)
+}
+
+func webhookData(webhookID uuid.UUID) []byte {
+	return []byte("webhook/" + webhookID.String())
+}
+
+// Lookup loads and decrypts the secret name owned by userID.
+func Lookup(userID uuid.UUID, name string) (string, error) {
+	var secret models.Secret
+	if err := db.DB.Where("user_id = ? AND name = ?", userID, name).First(&secret).Error; err != nil {
+		return "", fmt.Errorf("secret %q not found", name)
+	}
+	return Decrypt(&secret)
+}
@@ -1,6 +1,9 @@
 package server
 
 import (
+	"context"
+	"net/http"
+
 	"github.com/akhilbisht798/gocrony/internal/api"
 	"github.com/akhilbisht798/gocrony/internal/middleware"
 	"github.com/gin-gonic/gin"
@@ -8,19 +11,27 @@ import (
 
 type Server struct {
 	Router *gin.Engine
+	http   *http.Server
 }
 
-func NewServer() *Server {
+// NewServer registers the routes and prepares the server for addr. Everything
+// is set up here so Shutdown can safely run while Run is still starting.
+func NewServer(addr string) *Server {
 	router := gin.Default()
 
 	s := &Server{
 		Router: router,
+		http: &http.Server{
+			Addr:    addr,
+			Handler: router,
+		},
 	}
+	s.routes()
 
 	return s
 }
 
-func (s *Server) Run(addr string) {
+func (s *Server) routes() {
 	public := s.Router.Group("/api/v1")
 	{
 		public.POST("/signup", api.EmailPasswordAuthSignUp)
@@ -41,7 +52,47 @@ func (s *Server) Run(addr string) {
 
 		auth.POST("/jobs/:id/run", api.RunJob)
 		auth.GET("/jobs/:id/logs", api.GetLogs)
+		auth.GET("/jobs/:id/upcoming", api.GetUpcomingRuns)
+
+		auth.POST("/workflows", api.CreateWorkflow)
+		auth.GET("/workflows", api.GetAllWorkflows)
+		auth.GET("/workflows/:id", api.GetWorkflow)
+		auth.DELETE("/workflows/:id", api.DeleteWorkflow)
+		auth.GET("/workflows/:id/runs", api.GetWorkflowRuns)
+
+		auth.POST("/secrets", api.CreateSecret)
+		auth.GET("/secrets", api.GetAllSecrets)
+		auth.PUT("/secrets/:name", api.RotateSecret)
+		auth.DELETE("/secrets/:name", api.DeleteSecret)
+
+		auth.POST("/calendars", api.CreateCalendar)
+		auth.GET("/calendars", api.GetAllCalendars)
+		auth.GET("/calendars/:id", api.GetCalendar)
+		auth.PUT("/calendars/:id", api.UpdateCalendar)
+		auth.DELETE("/calendars/:id", api.DeleteCalendar)
+
+		auth.POST("/webhooks", api.CreateWebhook)
+		auth.GET("/webhooks", api.GetAllWebhooks)
+		auth.DELETE("/webhooks/:id", api.DeleteWebhook)
+		auth.GET("/webhooks/:id/deliveries", api.GetWebhookDeliveries)
+	}
+
+	admin := s.Router.Group("/api/v1/admin")
+	admin.Use(middleware.AuthMiddleWare(), middleware.AdminMiddleWare())
+	{
+		admin.GET("/jobs/stuck", api.GetStuckJobs)
+		admin.GET("/scheduler/leader", api.GetSchedulerLeader)
 	}
 
-	s.Router.Run(addr)
+}
+
+// Run serves until Shutdown is called, in which case it returns
+// http.ErrServerClosed.
+func (s *Server) Run() error {
+	return s.http.ListenAndServe()
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
 }
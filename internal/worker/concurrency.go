@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/cache"
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// LOCK_PREFIX keys hold the id of the run currently executing a job.
+	LOCK_PREFIX = "jobs:lock:"
+	// CANCEL_CHANNEL carries the ids of runs that must stop because a newer
+	// run of the same job replaced them.
+	CANCEL_CHANNEL = "jobs:cancel"
+	// REPLACE_WAIT bounds how long a replacing run waits for the old run to
+	// let go of the lock before taking it over.
+	REPLACE_WAIT = 30 * time.Second
+)
+
+var errRunReplaced = errors.New("run replaced by a newer run")
+
+var releaseLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// running holds the cancel funcs of the runs executing in this process.
+var running = struct {
+	sync.Mutex
+	runs map[string]context.CancelCauseFunc
+}{runs: make(map[string]context.CancelCauseFunc)}
+
+func trackRun(r *run, cancel context.CancelCauseFunc) func() {
+	id := r.ID.String()
+	running.Lock()
+	running.runs[id] = cancel
+	running.Unlock()
+	return func() {
+		running.Lock()
+		delete(running.runs, id)
+		running.Unlock()
+	}
+}
+
+// lockTTL outlives the longest the run can execute, so a crashed worker's
+// lock goes away on its own.
+func lockTTL(job *models.Job) time.Duration {
+	return JobTimeout(job) + time.Minute
+}
+
+// acquireRunLock applies the job's concurrency policy to r and reports
+// whether r may run. Allow takes no lock; forbid refuses while another run
+// holds it; replace cancels the holder and waits for it to let go.
+func acquireRunLock(ctx context.Context, job *models.Job, r *run) (bool, error) {
+	policy := job.ConcurrencyPolicy
+	if policy == "" || policy == models.ConcurrencyAllow {
+		return true, nil
+	}
+
+	key := LOCK_PREFIX + job.ID.String()
+	ttl := lockTTL(job)
+	ok, err := cache.Rbd.SetNX(ctx, key, r.ID.String(), ttl).Result()
+	if err != nil || ok {
+		return ok, err
+	}
+	if policy == models.ConcurrencyForbid {
+		return false, nil
+	}
+
+	holder, err := cache.Rbd.Get(ctx, key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+	if holder != "" {
+		if err := cache.Rbd.Publish(ctx, CANCEL_CHANNEL, holder).Err(); err != nil {
+			return false, err
+		}
+	}
+
+	deadline := time.Now().Add(REPLACE_WAIT)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+		ok, err := cache.Rbd.SetNX(ctx, key, r.ID.String(), ttl).Result()
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	// The holder never let go, most likely because its worker is gone. Its
+	// release is a compare and delete, so overwriting the lock is safe.
+	log.Printf("Job %s: run %s did not stop in %s, taking over its lock", job.ID, holder, REPLACE_WAIT)
+	return true, cache.Rbd.Set(ctx, key, r.ID.String(), ttl).Err()
+}
+
+func releaseRunLock(job *models.Job, r *run) {
+	if job.ConcurrencyPolicy == "" || job.ConcurrencyPolicy == models.ConcurrencyAllow {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	key := LOCK_PREFIX + job.ID.String()
+	if err := releaseLockScript.Run(ctx, cache.Rbd, []string{key}, r.ID.String()).Err(); err != nil {
+		log.Printf("Job %s: unable to release lock of run %s: %v", job.ID, r.ID, err)
+	}
+}
+
+// startCancelListener cancels runs of this process named on CANCEL_CHANNEL
+// until ctx is done.
+func startCancelListener(ctx context.Context) {
+	sub := cache.Rbd.Subscribe(ctx, CANCEL_CHANNEL)
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			running.Lock()
+			cancel, found := running.runs[msg.Payload]
+			running.Unlock()
+			if found {
+				log.Printf("Run %s replaced, cancelling it", msg.Payload)
+				cancel(errRunReplaced)
+			}
+		}
+	}
+}
+
+// isReplaced reports whether the run behind ctx was cancelled by a newer run.
+func isReplaced(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRunReplaced)
+}
+
+// skipRun records a run the concurrency policy refused. A scheduled run moves
+// the job on to its next slot, unless the running execution already did.
+func (w *Worker) skipRun(ctx context.Context, job *models.Job, reason string) {
+	log.Printf("Worker %s: skipping run of job %s: %s", w.ID, job.ID, reason)
+	w.logJobExecution(ctx, job.ID.String(), models.LogStatusSkipped, 0, reason, 0)
+
+	if r := runFromContext(ctx); r != nil && r.WorkflowNodeRunID != uuid.Nil {
+		w.finishWorkflowNode(r, models.StatusFailed)
+		return
+	}
+	// A one-time job only has the occurrence the running execution is
+	// already handling.
+	if job.OneTime() {
+		return
+	}
+	// Count from the skipped occurrence when it is still ahead of this
+	// worker's clock, otherwise the job would be left due and re-enqueued on
+	// every pass until the running execution finishes.
+	after := time.Now()
+	if job.NextRun != nil && job.NextRun.After(after) {
+		after = *job.NextRun
+	}
+	nextRun, err := scheduler.NextOccurrence(job, after)
+	if err != nil {
+		log.Printf("Worker %s: unable to compute next run of job %s: %v", w.ID, job.ID, err)
+		return
+	}
+	err = db.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ?", job.ID, models.StatusQueued).
+		Updates(map[string]any{"status": models.StatusPending, "next_run": nextRun}).Error
+	if err != nil {
+		log.Printf("Worker %s: unable to reschedule job %s: %v", w.ID, job.ID, err)
+		return
+	}
+	scheduler.Wake(ctx)
+}
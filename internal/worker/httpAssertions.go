@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPAssertions decide whether a completed round-trip counts as a success.
+// Without any StatusCodes only 2xx responses pass.
+type HTTPAssertions struct {
+	StatusCodes  []string           `json:"status_codes,omitempty"` // "200", "2xx" or "200-299"
+	BodyContains string             `json:"body_contains,omitempty"`
+	BodyRegex    string             `json:"body_regex,omitempty"`
+	JSONPath     *JSONPathAssertion `json:"json_path,omitempty"`
+	MaxLatencyMs int64              `json:"max_latency_ms,omitempty"`
+}
+
+// JSONPathAssertion compares the value at Path, e.g. "$.data.items[0].state",
+// with Equals.
+type JSONPathAssertion struct {
+	Path   string `json:"path"`
+	Equals any    `json:"equals"`
+}
+
+type assertionError struct {
+	Assertion string
+	Message   string
+}
+
+func (e *assertionError) Error() string {
+	return fmt.Sprintf("assertion %s failed: %s", e.Assertion, e.Message)
+}
+
+func (a *HTTPAssertions) validate() error {
+	if a == nil {
+		return nil
+	}
+	for _, pattern := range a.StatusCodes {
+		if _, _, err := parseStatusRange(pattern); err != nil {
+			return err
+		}
+	}
+	if a.BodyRegex != "" {
+		if _, err := regexp.Compile(a.BodyRegex); err != nil {
+			return fmt.Errorf("invalid body_regex: %w", err)
+		}
+	}
+	if a.JSONPath != nil {
+		if _, err := parseJSONPath(a.JSONPath.Path); err != nil {
+			return err
+		}
+	}
+	if a.MaxLatencyMs < 0 {
+		return fmt.Errorf("max_latency_ms must not be negative")
+	}
+	return nil
+}
+
+// check returns an *assertionError for the first assertion that fails.
+func (a *HTTPAssertions) check(statusCode int, body []byte, latency time.Duration) error {
+	if a == nil {
+		a = &HTTPAssertions{}
+	}
+
+	codes := a.StatusCodes
+	if len(codes) == 0 {
+		codes = []string{"2xx"}
+	}
+	if !statusMatches(codes, statusCode) {
+		return &assertionError{"status_codes", fmt.Sprintf("got %d, want one of %s", statusCode, strings.Join(codes, ", "))}
+	}
+
+	if a.MaxLatencyMs > 0 && latency > time.Duration(a.MaxLatencyMs)*time.Millisecond {
+		return &assertionError{"max_latency_ms", fmt.Sprintf("took %dms, limit %dms", latency.Milliseconds(), a.MaxLatencyMs)}
+	}
+
+	if a.BodyContains != "" && !bytes.Contains(body, []byte(a.BodyContains)) {
+		return &assertionError{"body_contains", fmt.Sprintf("body does not contain %q", a.BodyContains)}
+	}
+
+	if a.BodyRegex != "" {
+		re, err := regexp.Compile(a.BodyRegex)
+		if err != nil {
+			return &assertionError{"body_regex", err.Error()}
+		}
+		if !re.Match(body) {
+			return &assertionError{"body_regex", fmt.Sprintf("body does not match %q", a.BodyRegex)}
+		}
+	}
+
+	if a.JSONPath != nil {
+		if err := checkJSONPath(a.JSONPath, body); err != nil {
+			return &assertionError{"json_path", err.Error()}
+		}
+	}
+	return nil
+}
+
+func statusMatches(patterns []string, statusCode int) bool {
+	for _, pattern := range patterns {
+		low, high, err := parseStatusRange(pattern)
+		if err == nil && statusCode >= low && statusCode <= high {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatusRange turns "200", "2xx" or "200-299" into an inclusive range.
+func parseStatusRange(pattern string) (int, int, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if len(pattern) == 3 && strings.HasSuffix(pattern, "xx") {
+		class, err := strconv.Atoi(pattern[:1])
+		if err == nil && class >= 1 && class <= 5 {
+			return class * 100, class*100 + 99, nil
+		}
+	}
+	if low, high, ok := strings.Cut(pattern, "-"); ok {
+		l, errLow := strconv.Atoi(low)
+		h, errHigh := strconv.Atoi(high)
+		if errLow == nil && errHigh == nil && l <= h {
+			return l, h, nil
+		}
+	}
+	if code, err := strconv.Atoi(pattern); err == nil {
+		return code, code, nil
+	}
+	return 0, 0, fmt.Errorf("invalid status code pattern %q", pattern)
+}
+
+func checkJSONPath(assertion *JSONPathAssertion, body []byte) error {
+	path, err := parseJSONPath(assertion.Path)
+	if err != nil {
+		return err
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Errorf("body is not valid JSON: %w", err)
+	}
+
+	value := doc
+	for _, key := range path {
+		switch node := value.(type) {
+		case map[string]any:
+			v, ok := node[key]
+			if !ok {
+				return fmt.Errorf("%s: key %q not found", assertion.Path, key)
+			}
+			value = v
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return fmt.Errorf("%s: index %q out of range", assertion.Path, key)
+			}
+			value = node[i]
+		default:
+			return fmt.Errorf("%s: cannot descend into %q", assertion.Path, key)
+		}
+	}
+
+	// Compare through JSON so 1 and 1.0, or differently ordered objects, are
+	// treated as equal.
+	got, _ := json.Marshal(value)
+	want, _ := json.Marshal(normalizeJSON(assertion.Equals))
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%s is %s, want %s", assertion.Path, got, want)
+	}
+	return nil
+}
+
+func normalizeJSON(v any) any {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// parseJSONPath supports the dotted subset of JSONPath: "$.a.b[0].c".
+func parseJSONPath(path string) ([]string, error) {
+	if path != "$" && !strings.HasPrefix(path, "$.") && !strings.HasPrefix(path, "$[") {
+		return nil, fmt.Errorf("invalid json path %q, must start with $", path)
+	}
+	var keys []string
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid json path %q", path)
+			}
+			keys = append(keys, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid json path %q", path)
+			}
+			key := strings.Trim(rest[1:end], `'"`)
+			if key == "" {
+				return nil, fmt.Errorf("invalid json path %q", path)
+			}
+			keys = append(keys, key)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid json path %q", path)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		low, high int
+		wantErr   bool
+	}{
+		{"200", 200, 200, false},
+		{" 2XX ", 200, 299, false},
+		{"5xx", 500, 599, false},
+		{"200-204", 200, 204, false},
+		{"6xx", 0, 0, true},
+		{"204-200", 0, 0, true},
+		{"ok", 0, 0, true},
+	}
+	for _, tt := range tests {
+		low, high, err := parseStatusRange(tt.pattern)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStatusRange(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			continue
+		}
+		if low != tt.low || high != tt.high {
+			t.Errorf("parseStatusRange(%q) = %d-%d, want %d-%d", tt.pattern, low, high, tt.low, tt.high)
+		}
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []string
+		wantErr bool
+	}{
+		{"$", nil, false},
+		{"$.status", []string{"status"}, false},
+		{"$.data.items[0].state", []string{"data", "items", "0", "state"}, false},
+		{"$['odd.key'].value", []string{"odd.key", "value"}, false},
+		{"status", nil, true},
+		{"$..status", nil, true},
+		{"$.items[0", nil, true},
+		{"$[]", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseJSONPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseJSONPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPAssertionsCheck(t *testing.T) {
+	body := []byte(`{"status":"ok","data":{"items":[{"state":"done","count":1}]}}`)
+	tests := []struct {
+		name       string
+		assertions *HTTPAssertions
+		status     int
+		body       []byte
+		latency    time.Duration
+		failed     string
+	}{
+		{"no assertions pass a 2xx", nil, 204, nil, 0, ""},
+		{"no assertions fail a 3xx", nil, 302, nil, 0, "status_codes"},
+		{"explicit status", &HTTPAssertions{StatusCodes: []string{"404"}}, 404, nil, 0, ""},
+		{"status outside every pattern", &HTTPAssertions{StatusCodes: []string{"200", "3xx"}}, 404, nil, 0, "status_codes"},
+		{"latency within the limit", &HTTPAssertions{MaxLatencyMs: 100}, 200, nil, 100 * time.Millisecond, ""},
+		{"latency over the limit", &HTTPAssertions{MaxLatencyMs: 100}, 200, nil, 101 * time.Millisecond, "max_latency_ms"},
+		{"body contains", &HTTPAssertions{BodyContains: `"ok"`}, 200, body, 0, ""},
+		{"body does not contain", &HTTPAssertions{BodyContains: "error"}, 200, body, 0, "body_contains"},
+		{"body matches", &HTTPAssertions{BodyRegex: `"count":\d+`}, 200, body, 0, ""},
+		{"body does not match", &HTTPAssertions{BodyRegex: `^\[`}, 200, body, 0, "body_regex"},
+		{"json path string", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "$.data.items[0].state", Equals: "done"}}, 200, body, 0, ""},
+		{"json path number", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "$.data.items[0].count", Equals: 1.0}}, 200, body, 0, ""},
+		{"json path object", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "$.data.items[0]", Equals: map[string]any{"count": 1, "state": "done"}}}, 200, body, 0, ""},
+		{"json path differs", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "$.status", Equals: "failed"}}, 200, body, 0, "json_path"},
+		{"json path missing key", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "$.missing", Equals: nil}}, 200, body, 0, "json_path"},
+		{"json path index out of range", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "$.data.items[3]", Equals: nil}}, 200, body, 0, "json_path"},
+		{"json path on a non JSON body", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "$.status", Equals: "ok"}}, 200, []byte("ok"), 0, "json_path"},
+		{"status is checked first", &HTTPAssertions{BodyContains: "error"}, 500, body, 0, "status_codes"},
+	}
+	for _, tt := range tests {
+		err := tt.assertions.check(tt.status, tt.body, tt.latency)
+		if tt.failed == "" {
+			if err != nil {
+				t.Errorf("%s: check = %v, want it to pass", tt.name, err)
+			}
+			continue
+		}
+		var failure *assertionError
+		if !errors.As(err, &failure) || failure.Assertion != tt.failed {
+			t.Errorf("%s: check = %v, want %s to fail", tt.name, err, tt.failed)
+		}
+	}
+}
+
+func TestHTTPAssertionsValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		assertions *HTTPAssertions
+		wantErr    bool
+	}{
+		{"nil", nil, false},
+		{"valid", &HTTPAssertions{StatusCodes: []string{"2xx", "404"}, BodyRegex: "ok", JSONPath: &JSONPathAssertion{Path: "$.ok"}}, false},
+		{"bad status", &HTTPAssertions{StatusCodes: []string{"two hundred"}}, true},
+		{"bad regex", &HTTPAssertions{BodyRegex: "("}, true},
+		{"bad json path", &HTTPAssertions{JSONPath: &JSONPathAssertion{Path: "ok"}}, true},
+		{"negative latency", &HTTPAssertions{MaxLatencyMs: -1}, true},
+	}
+	for _, tt := range tests {
+		err := tt.assertions.validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package worker
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	HTTPAuthBasic             = "basic"
+	HTTPAuthBearer            = "bearer"
+	HTTPAuthClientCredentials = "oauth2_client_credentials"
+	HTTPAuthHMAC              = "hmac"
+)
+
+const (
+	DEFAULT_HMAC_HEADER           = "X-Signature"
+	DEFAULT_HMAC_TIMESTAMP_HEADER = "X-Timestamp"
+	DEFAULT_HMAC_CANONICAL_STRING = "{method}\n{path}\n{timestamp}\n{body}"
+)
+
+// HTTPAuth authenticates the outgoing request of an http job. Only the fields
+// of the selected Type are used.
+type HTTPAuth struct {
+	Type string `json:"type"`
+
+	// basic
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// bearer
+	Token string `json:"token,omitempty"`
+
+	// oauth2_client_credentials
+	TokenURL       string            `json:"token_url,omitempty"`
+	ClientID       string            `json:"client_id,omitempty"`
+	ClientSecret   string            `json:"client_secret,omitempty"`
+	Scopes         []string          `json:"scopes,omitempty"`
+	EndpointParams map[string]string `json:"endpoint_params,omitempty"` // e.g. audience
+
+	// hmac. CanonicalString may use {method}, {path}, {query}, {host},
+	// {timestamp}, {body} and {body_sha256}.
+	Secret          string `json:"secret,omitempty"`
+	Header          string `json:"header,omitempty"`
+	TimestampHeader string `json:"timestamp_header,omitempty"`
+	Algorithm       string `json:"algorithm,omitempty"` // sha256 (default), sha512 or sha1
+	Encoding        string `json:"encoding,omitempty"`  // hex (default) or base64
+	Prefix          string `json:"prefix,omitempty"`    // e.g. "sha256="
+	CanonicalString string `json:"canonical_string,omitempty"`
+}
+
+func (a *HTTPAuth) validate() error {
+	if a == nil {
+		return nil
+	}
+	switch a.Type {
+	case HTTPAuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("auth: username is required")
+		}
+	case HTTPAuthBearer:
+		if a.Token == "" {
+			return fmt.Errorf("auth: token is required")
+		}
+	case HTTPAuthClientCredentials:
+		if a.TokenURL == "" || a.ClientID == "" || a.ClientSecret == "" {
+			return fmt.Errorf("auth: token_url, client_id and client_secret are required")
+		}
+	case HTTPAuthHMAC:
+		if a.Secret == "" {
+			return fmt.Errorf("auth: secret is required")
+		}
+		if _, err := hmacHash(a.Algorithm); err != nil {
+			return err
+		}
+		if a.Encoding != "" && a.Encoding != "hex" && a.Encoding != "base64" {
+			return fmt.Errorf("auth: unknown encoding %q", a.Encoding)
+		}
+	default:
+		return fmt.Errorf("auth: unknown type %q", a.Type)
+	}
+	return nil
+}
+
+// apply adds credentials to req. body must be the exact bytes sent.
+func (a *HTTPAuth) apply(ctx context.Context, req *http.Request, body string, jobId string) error {
+	if a == nil {
+		return nil
+	}
+	switch a.Type {
+	case HTTPAuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+	case HTTPAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	case HTTPAuthClientCredentials:
+		token, err := clientCredentialsToken(ctx, a, jobId)
+		if err != nil {
+			return fmt.Errorf("fetching oauth2 token: %w", err)
+		}
+		token.SetAuthHeader(req)
+	case HTTPAuthHMAC:
+		return a.sign(req, body, time.Now())
+	default:
+		return fmt.Errorf("auth: unknown type %q", a.Type)
+	}
+	return nil
+}
+
+func (a *HTTPAuth) sign(req *http.Request, body string, now time.Time) error {
+	newHash, err := hmacHash(a.Algorithm)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	bodySum := sha256.Sum256([]byte(body))
+
+	canonical := a.CanonicalString
+	if canonical == "" {
+		canonical = DEFAULT_HMAC_CANONICAL_STRING
+	}
+	canonical = strings.NewReplacer(
+		"{method}", req.Method,
+		"{path}", req.URL.RequestURI(),
+		"{query}", req.URL.RawQuery,
+		"{host}", req.URL.Host,
+		"{timestamp}", timestamp,
+		"{body}", body,
+		"{body_sha256}", hex.EncodeToString(bodySum[:]),
+	).Replace(canonical)
+
+	mac := hmac.New(newHash, []byte(a.Secret))
+	mac.Write([]byte(canonical))
+	var signature string
+	if a.Encoding == "base64" {
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	} else {
+		signature = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	header := a.Header
+	if header == "" {
+		header = DEFAULT_HMAC_HEADER
+	}
+	timestampHeader := a.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = DEFAULT_HMAC_TIMESTAMP_HEADER
+	}
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(header, a.Prefix+signature)
+	return nil
+}
+
+func hmacHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	case "sha1":
+		return sha1.New, nil
+	}
+	return nil, fmt.Errorf("auth: unknown hmac algorithm %q", algorithm)
+}
+
+type cachedToken struct {
+	credentials string
+	token       *oauth2.Token
+}
+
+// tokenCache holds one client credentials token per job until it expires.
+// It is shared by every worker in the process.
+var (
+	tokenCacheMu sync.Mutex
+	tokenCache   = map[string]cachedToken{}
+)
+
+func clientCredentialsToken(ctx context.Context, a *HTTPAuth, jobId string) (*oauth2.Token, error) {
+	// Changing any credential on the job must not reuse the old token.
+	credentials := strings.Join([]string{a.TokenURL, a.ClientID, a.ClientSecret, strings.Join(a.Scopes, " "), fmt.Sprint(a.EndpointParams)}, "\x00")
+
+	tokenCacheMu.Lock()
+	cached, ok := tokenCache[jobId]
+	tokenCacheMu.Unlock()
+	if ok && cached.credentials == credentials && cached.token.Valid() {
+		return cached.token, nil
+	}
+
+	cfg := clientcredentials.Config{
+		ClientID:     a.ClientID,
+		ClientSecret: a.ClientSecret,
+		TokenURL:     a.TokenURL,
+		Scopes:       a.Scopes,
+	}
+	if len(a.EndpointParams) > 0 {
+		cfg.EndpointParams = map[string][]string{}
+		for k, v := range a.EndpointParams {
+			cfg.EndpointParams[k] = []string{v}
+		}
+	}
+	token, err := cfg.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenCacheMu.Lock()
+	// Expired tokens are dropped on the way, a job that was deleted or whose
+	// credentials changed would otherwise keep its entry for good.
+	for id, cached := range tokenCache {
+		if !cached.token.Valid() {
+			delete(tokenCache, id)
+		}
+	}
+	tokenCache[jobId] = cachedToken{credentials: credentials, token: token}
+	tokenCacheMu.Unlock()
+	return token, nil
+}
+
+// ForgetJobToken drops the cached client credentials token of a deleted job.
+func ForgetJobToken(jobId string) {
+	tokenCacheMu.Lock()
+	delete(tokenCache, jobId)
+	tokenCacheMu.Unlock()
+}
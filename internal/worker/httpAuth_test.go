@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestClientCredentialsTokenCache(t *testing.T) {
+	var issued atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer srv.Close()
+
+	tokenCacheMu.Lock()
+	tokenCache = map[string]cachedToken{
+		"expired": {token: &oauth2.Token{AccessToken: "old", Expiry: time.Now().Add(-time.Minute)}},
+	}
+	tokenCacheMu.Unlock()
+
+	auth := &HTTPAuth{Type: HTTPAuthClientCredentials, TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret"}
+	rotated := *auth
+	rotated.ClientSecret = "rotated"
+	steps := []struct {
+		name   string
+		auth   *HTTPAuth
+		jobId  string
+		forget string
+		want   string
+	}{
+		{"first fetch", auth, "job-a", "", "token-1"},
+		{"reused while valid", auth, "job-a", "", "token-1"},
+		{"one token per job", auth, "job-b", "", "token-2"},
+		{"changed credentials fetch again", &rotated, "job-a", "", "token-3"},
+		{"forgotten on delete", auth, "job-b", "job-b", "token-4"},
+	}
+	for _, step := range steps {
+		if step.forget != "" {
+			ForgetJobToken(step.forget)
+		}
+		token, err := clientCredentialsToken(context.Background(), step.auth, step.jobId)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if token.AccessToken != step.want {
+			t.Errorf("%s: token = %q, want %q", step.name, token.AccessToken, step.want)
+		}
+	}
+
+	tokenCacheMu.Lock()
+	_, kept := tokenCache["expired"]
+	size := len(tokenCache)
+	tokenCacheMu.Unlock()
+	if kept || size != 2 {
+		t.Errorf("cache holds %d entries, expired entry kept %v", size, kept)
+	}
+}
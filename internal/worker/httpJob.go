@@ -0,0 +1,150 @@
+package worker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+type HTTPRequestPayload struct {
+	URL        string            `json:"url"`
+	Method     string            `json:"method"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	Auth       *HTTPAuth         `json:"auth,omitempty"`
+	Assertions *HTTPAssertions   `json:"assertions,omitempty"`
+}
+
+func (w *Worker) executeHttpJob(ctx context.Context, job *models.Job) error {
+	start := time.Now()
+
+	var payload HTTPRequestPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), int64(time.Since(start).Milliseconds()))
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	if payload.URL == "" {
+		err := fmt.Errorf("Url is required")
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), 0)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	if payload.Method == "" {
+		payload.Method = "GET"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, payload.Method, payload.URL, strings.NewReader(payload.Body))
+	if err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), int64(time.Since(start).Milliseconds()))
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+
+	for k, v := range payload.Headers {
+		req.Header.Set(k, v)
+	}
+	if err := payload.Auth.apply(ctx, req, payload.Body, job.ID.String()); err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), time.Since(start).Milliseconds())
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	resp, err := w.client.Do(req)
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		if !isRetryableHttpError(err) {
+			w.logJobExecution(ctx, job.ID.String(), string(models.StatusAborted), 0, "not retrying: "+err.Error(), duration)
+			w.updateJob(ctx, job, job.ID.String(), models.StatusAborted)
+			return err
+		}
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), duration)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	defer resp.Body.Close()
+	// Read past the logged prefix so body assertions see more than 10KB.
+	bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	logged := truncateUTF8(bodyBytes, MAX_LOGGED_RESPONSE)
+
+	if err := payload.Assertions.check(resp.StatusCode, bodyBytes, time.Duration(duration)*time.Millisecond); err != nil {
+		var assertErr *assertionError
+		if errors.As(err, &assertErr) && assertErr.Assertion == "status_codes" && !isRetryableStatus(resp.StatusCode) {
+			w.logJobExecution(ctx, job.ID.String(), string(models.StatusAborted), resp.StatusCode, "not retrying: "+err.Error()+"\n"+string(logged), duration)
+			w.updateJob(ctx, job, job.ID.String(), models.StatusAborted)
+			return err
+		}
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), resp.StatusCode, err.Error()+"\n"+string(logged), duration)
+		maxDelay := time.Duration(job.RetryPolicy.WithDefaults().MaxDelaySeconds) * time.Second
+		w.updateJobWithRetryAt(ctx, job, job.ID.String(), models.StatusFailed, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now(), maxDelay))
+		return err
+	}
+	w.logJobExecution(ctx, job.ID.String(), resp.Status, resp.StatusCode, string(logged), duration)
+	w.updateJob(ctx, job, job.ID.String(), models.StatusPending) // Pending means ready to run again.
+	return nil
+}
+
+// isRetryableStatus reports whether a failing status code is worth retrying.
+// Other 4xx responses mean the request itself is wrong and will keep failing.
+func isRetryableStatus(statusCode int) bool {
+	if statusCode >= 400 && statusCode < 500 {
+		return statusCode == http.StatusRequestTimeout || statusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// isRetryableHttpError separates transient transport failures (timeouts,
+// resets, refused connections, DNS errors) from ones that can't succeed on a
+// later attempt: an untrusted certificate or a URL we can't speak to.
+func isRetryableHttpError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) {
+		return false
+	}
+	if strings.Contains(err.Error(), "unsupported protocol scheme") {
+		return false
+	}
+	return true
+}
+
+// parseRetryAfter reads a Retry-After header given either as seconds or as an
+// HTTP date, capped at maxDelay from now. It returns nil when the header is
+// missing or unusable.
+func parseRetryAfter(value string, now time.Time, maxDelay time.Duration) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	var retryAt time.Time
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Clamped before converting so a huge value can't overflow.
+		seconds = min(max(seconds, 0), int64(maxDelay/time.Second))
+		retryAt = now.Add(time.Duration(seconds) * time.Second)
+	} else if date, err := http.ParseTime(value); err == nil {
+		retryAt = date
+	} else {
+		return nil
+	}
+	if retryAt.Before(now) {
+		retryAt = now
+	}
+	if latest := now.Add(maxDelay); retryAt.After(latest) {
+		retryAt = latest
+	}
+	retryAt = retryAt.UTC()
+	return &retryAt
+}
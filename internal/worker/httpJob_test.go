@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	maxDelay := time.Hour
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration // from now, ignored when isNil is set
+		isNil bool
+	}{
+		{"missing", "", 0, true},
+		{"seconds", "120", 2 * time.Minute, false},
+		{"seconds with spaces", " 30 ", 30 * time.Second, false},
+		{"zero", "0", 0, false},
+		{"negative seconds run now", "-5", 0, false},
+		{"seconds past the cap", "7200", time.Hour, false},
+		{"seconds that would overflow", "9223372036854775807", time.Hour, false},
+		{"http date", "Thu, 01 Jan 2026 12:10:00 GMT", 10 * time.Minute, false},
+		{"http date in the past", "Thu, 01 Jan 2026 11:00:00 GMT", 0, false},
+		{"http date past the cap", "Fri, 02 Jan 2026 12:00:00 GMT", time.Hour, false},
+		{"garbage", "soon", 0, true},
+		{"fractional seconds", "1.5", 0, true},
+	}
+	for _, tt := range tests {
+		got := parseRetryAfter(tt.value, now, maxDelay)
+		if tt.isNil {
+			if got != nil {
+				t.Errorf("%s: parseRetryAfter(%q) = %s, want nil", tt.name, tt.value, got)
+			}
+			continue
+		}
+		if got == nil || !got.Equal(now.Add(tt.want)) {
+			t.Errorf("%s: parseRetryAfter(%q) = %v, want %s", tt.name, tt.value, got, now.Add(tt.want))
+		}
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{400, false},
+		{401, false},
+		{404, false},
+		{408, true},
+		{429, true},
+		{500, true},
+		{503, true},
+	}
+	for _, tt := range tests {
+		if got := isRetryableStatus(tt.status); got != tt.want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
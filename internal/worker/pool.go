@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/akhilbisht798/gocrony/config"
+)
+
+const (
+	DEFAULT_WORKER_COUNT       = 1
+	DEFAULT_WORKER_CONCURRENCY = 10
+)
+
+// Pool runs several workers that share one cap on concurrent executions, so a
+// burst of due jobs can't start more than concurrency executions per process.
+type Pool struct {
+	Workers []*Worker
+	slots   chan struct{}
+}
+
+func NewPool(id string, count int, concurrency int) *Pool {
+	if count < 1 {
+		count = 1
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	p := &Pool{
+		slots: make(chan struct{}, concurrency),
+	}
+	for i := 0; i < count; i++ {
+		p.Workers = append(p.Workers, NewWorker(fmt.Sprintf("%s-%d", id, i), p.slots))
+	}
+	return p
+}
+
+// NewPoolFromConfig reads WORKER_COUNT and WORKER_CONCURRENCY.
+func NewPoolFromConfig(id string) *Pool {
+	return NewPool(
+		id,
+		config.GetEnvInt("WORKER_COUNT", DEFAULT_WORKER_COUNT),
+		config.GetEnvInt("WORKER_CONCURRENCY", DEFAULT_WORKER_CONCURRENCY),
+	)
+}
+
+// Start runs every worker until ctx is cancelled. Cancelling ctx only stops
+// popping new jobs, executions already started keep running; use Wait to
+// drain them.
+func (p *Pool) Start(ctx context.Context) {
+	go startCancelListener(ctx)
+	for _, w := range p.Workers {
+		go w.Start(ctx)
+	}
+}
+
+// Wait blocks until no execution holds a slot or ctx is done, and reports
+// whether the pool drained. Once it returns true the workers can't start new
+// executions. Jobs still running when ctx expires stay in the processing list
+// and are redelivered after their visibility timeout.
+func (p *Pool) Wait(ctx context.Context) bool {
+	for i := 0; i < cap(p.slots); i++ {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
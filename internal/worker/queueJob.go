@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/cache"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	QueueKindList   = "list"
+	QueueKindStream = "stream"
+)
+
+type QueueRequestPayload struct {
+	Kind    string            `json:"kind"`
+	Key     string            `json:"key"`
+	Message string            `json:"message,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`  // stream only, defaults to {"message": Message}
+	MaxLen  int64             `json:"max_len,omitempty"` // stream only, approximate trim
+}
+
+func (w *Worker) executeQueueJob(ctx context.Context, job *models.Job) error {
+	start := time.Now()
+
+	var payload QueueRequestPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), int64(time.Since(start).Milliseconds()))
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	if err := validateQueuePayload(&payload); err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), 0)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+
+	var command, response string
+	var err error
+	switch payload.Kind {
+	case QueueKindList:
+		var length int64
+		command = "LPUSH"
+		length, err = cache.Rbd.LPush(ctx, payload.Key, payload.Message).Result()
+		response = "list length " + strconv.FormatInt(length, 10)
+	case QueueKindStream:
+		values := payload.Fields
+		if len(values) == 0 {
+			values = map[string]string{"message": payload.Message}
+		}
+		command = "XADD"
+		response, err = cache.Rbd.XAdd(ctx, &redis.XAddArgs{
+			Stream: payload.Key,
+			MaxLen: payload.MaxLen,
+			Approx: payload.MaxLen > 0,
+			Values: values,
+		}).Result()
+	}
+	duration := time.Since(start).Milliseconds()
+
+	if err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), duration)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	w.logJobExecution(ctx, job.ID.String(), command, 0, response, duration)
+	w.updateJob(ctx, job, job.ID.String(), models.StatusPending)
+	return nil
+}
+
+func validateQueuePayload(payload *QueueRequestPayload) error {
+	if payload.Kind == "" {
+		payload.Kind = QueueKindList
+	}
+	if payload.Kind != QueueKindList && payload.Kind != QueueKindStream {
+		return fmt.Errorf("unknown queue kind %q, expected list or stream", payload.Kind)
+	}
+	if payload.Key == "" {
+		return fmt.Errorf("Key is required")
+	}
+	// Never let a job write into gocrony's own queues.
+	if payload.Key == scheduler.QUEUE || strings.HasPrefix(payload.Key, scheduler.QUEUE+":") {
+		return fmt.Errorf("key %q is reserved", payload.Key)
+	}
+	if payload.Kind == QueueKindList && payload.Message == "" {
+		return fmt.Errorf("Message is required")
+	}
+	if payload.Kind == QueueKindStream && payload.Message == "" && len(payload.Fields) == 0 {
+		return fmt.Errorf("Message or Fields is required")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/config"
+	"github.com/akhilbisht798/gocrony/internal/cache"
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DEFAULT_VISIBILITY_TIMEOUT = 60 * time.Second
+	REDELIVERY_INTERVAL        = 15 * time.Second
+)
+
+// redeliverScript moves one expired entry from the processing list back onto
+// the main queue. The deadline is checked again here, a heartbeat may have
+// renewed it since the scan, and it only pushes when the entry was still in
+// processing, so concurrent reapers and a late ack can't produce a duplicate.
+var redeliverScript = redis.NewScript(`
+local deadline = redis.call('ZSCORE', KEYS[2], ARGV[1])
+if deadline and tonumber(deadline) > tonumber(ARGV[2]) then
+	return 0
+end
+local removed = redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+if removed > 0 then
+	redis.call('LPUSH', KEYS[3], ARGV[1])
+end
+return removed
+`)
+
+func visibilityTimeout() time.Duration {
+	seconds := config.GetEnvInt("QUEUE_VISIBILITY_TIMEOUT_SECONDS", 0)
+	if seconds <= 0 {
+		return DEFAULT_VISIBILITY_TIMEOUT
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// touchInflight pushes the visibility deadline of jobId forward.
+func touchInflight(ctx context.Context, jobId string) error {
+	deadline := time.Now().Add(visibilityTimeout())
+	return cache.Rbd.ZAdd(ctx, scheduler.INFLIGHT_QUEUE, redis.Z{
+		Score:  float64(deadline.Unix()),
+		Member: jobId,
+	}).Err()
+}
+
+// heartbeat keeps jobId invisible to the reaper while it is executing and
+// returns a func that stops it.
+func heartbeat(jobId string) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(visibilityTimeout() / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := touchInflight(ctx, jobId); err != nil && ctx.Err() == nil {
+					log.Printf("Error extending visibility of %s: %v", jobId, err)
+				}
+			}
+		}
+	}()
+	return cancel
+}
+
+// ackJob removes a finished entry from the processing list.
+func ackJob(jobId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := cache.Rbd.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, scheduler.PROCESSING_QUEUE, 1, jobId)
+		pipe.ZRem(ctx, scheduler.INFLIGHT_QUEUE, jobId)
+		return nil
+	})
+	return err
+}
+
+// StartRedelivery periodically puts entries whose visibility timeout expired
+// back on the queue. It is safe to run in every process.
+func StartRedelivery(ctx context.Context) {
+	log.Println("queue redelivery started")
+	ticker := time.NewTicker(REDELIVERY_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := redeliverExpired(ctx); err != nil {
+				log.Printf("Error redelivering expired jobs: %v", err)
+			}
+		}
+	}
+}
+
+func redeliverExpired(ctx context.Context) error {
+	// Entries that were moved but never got a deadline (the worker died
+	// between BLMOVE and ZADD) get one now, so they expire like the rest.
+	processing, err := cache.Rbd.LRange(ctx, scheduler.PROCESSING_QUEUE, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	deadline := float64(time.Now().Add(visibilityTimeout()).Unix())
+	for _, jobId := range processing {
+		err := cache.Rbd.ZAddNX(ctx, scheduler.INFLIGHT_QUEUE, redis.Z{Score: deadline, Member: jobId}).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	expired, err := cache.Rbd.ZRangeByScore(ctx, scheduler.INFLIGHT_QUEUE, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+	for _, jobId := range expired {
+		keys := []string{scheduler.PROCESSING_QUEUE, scheduler.INFLIGHT_QUEUE, scheduler.QUEUE}
+		removed, err := redeliverScript.Run(ctx, cache.Rbd, keys, jobId, time.Now().Unix()).Int()
+		if err != nil {
+			return err
+		}
+		if removed > 0 {
+			log.Printf("Redelivered job %s after visibility timeout", jobId)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+// run describes a single execution of a job. It travels in the execution
+// context so logs and payload templates can refer to it.
+type run struct {
+	ID          uuid.UUID
+	ScheduledAt time.Time
+	FiredAt     time.Time
+	Attempt     int
+
+	// Log is the last entry written for the run, it feeds notifications.
+	Log *models.Logs
+
+	// WorkflowNodeRunID is set when the run was started by a workflow.
+	WorkflowNodeRunID uuid.UUID
+}
+
+type runKey struct{}
+
+func withRun(ctx context.Context, r *run) context.Context {
+	return context.WithValue(ctx, runKey{}, r)
+}
+
+func runFromContext(ctx context.Context) *run {
+	r, _ := ctx.Value(runKey{}).(*run)
+	return r
+}
@@ -0,0 +1,259 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/config"
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+const DEFAULT_SHELL_MAX_OUTPUT = 64 * 1024
+
+type ShellRequestPayload struct {
+	Command        string            `json:"command"`
+	Args           []string          `json:"args,omitempty"`
+	Shell          bool              `json:"shell,omitempty"` // run Command through sh -c, sh must be allowlisted
+	Dir            string            `json:"dir,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
+	MaxOutputBytes int               `json:"max_output_bytes,omitempty"`
+}
+
+type shellJobResult struct {
+	ExitCode  int    `json:"exit_code"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// ShellJobsEnabled reports whether the operator opted in to shell jobs.
+func ShellJobsEnabled() bool {
+	return config.GetEnvBool("SHELL_JOBS_ENABLED", false)
+}
+
+// ValidateShellPayload checks a shell payload against the operator config so
+// bad jobs are rejected at creation instead of failing on every run.
+func ValidateShellPayload(raw json.RawMessage) error {
+	var payload ShellRequestPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return err
+	}
+	_, err := shellBinary(&payload)
+	if err != nil {
+		return err
+	}
+	if _, err := shellDir(payload.Dir); err != nil {
+		return err
+	}
+	return validateShellEnv(payload.Env)
+}
+
+// shellBinary resolves the binary to execute and makes sure it is allowlisted.
+// The path found on PATH is compared as is: following symlinks would let any
+// applet of a multi-call binary such as busybox through once one is allowed.
+func shellBinary(payload *ShellRequestPayload) (string, error) {
+	if !ShellJobsEnabled() {
+		return "", errors.New("shell jobs are disabled")
+	}
+	if payload.Command == "" {
+		return "", errors.New("Command is required")
+	}
+	name := payload.Command
+	if payload.Shell {
+		name = "sh"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for _, allowed := range config.GetEnvList("SHELL_JOBS_ALLOWED_BINARIES") {
+		if filepath.IsAbs(allowed) && filepath.Clean(allowed) == path {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("binary %q (%s) is not in SHELL_JOBS_ALLOWED_BINARIES", name, path)
+}
+
+// shellDir checks the working directory is inside one of
+// SHELL_JOBS_ALLOWED_DIRS. An empty dir runs in the worker's own directory.
+func shellDir(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("dir %q must be an absolute path", dir)
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	for _, allowed := range config.GetEnvList("SHELL_JOBS_ALLOWED_DIRS") {
+		root, err := filepath.EvalSymlinks(allowed)
+		if err != nil || !filepath.IsAbs(root) {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("dir %q is not in SHELL_JOBS_ALLOWED_DIRS", dir)
+}
+
+// blockedEnvPrefixes are variables that change what the child actually runs:
+// the loader, the search path and shell startup hooks.
+var blockedEnvPrefixes = []string{"LD_", "DYLD_", "BASH_FUNC_"}
+
+var blockedEnv = map[string]bool{
+	"PATH":       true,
+	"IFS":        true,
+	"ENV":        true,
+	"BASH_ENV":   true,
+	"SHELLOPTS":  true,
+	"BASHOPTS":   true,
+	"PS4":        true,
+	"GCONV_PATH": true,
+}
+
+func validateShellEnv(env map[string]string) error {
+	for key := range env {
+		if key == "" || strings.ContainsAny(key, "=\x00") {
+			return fmt.Errorf("invalid env name %q", key)
+		}
+		upper := strings.ToUpper(key)
+		if blockedEnv[upper] {
+			return fmt.Errorf("env %q is not allowed", key)
+		}
+		for _, prefix := range blockedEnvPrefixes {
+			if strings.HasPrefix(upper, prefix) {
+				return fmt.Errorf("env %q is not allowed", key)
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Worker) executeShellJob(ctx context.Context, job *models.Job) error {
+	start := time.Now()
+
+	var payload ShellRequestPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), int64(time.Since(start).Milliseconds()))
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	path, err := shellBinary(&payload)
+	if err == nil {
+		err = validateShellEnv(payload.Env)
+	}
+	var dir string
+	if err == nil {
+		dir, err = shellDir(payload.Dir)
+	}
+	if err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), 0)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+
+	// The job's own timeout already bounds ctx, the payload and operator
+	// limits can only shorten it.
+	timeout := JobTimeout(job)
+	if payload.TimeoutSeconds > 0 && payload.TimeoutSeconds < int(timeout/time.Second) {
+		timeout = time.Duration(payload.TimeoutSeconds) * time.Second
+	}
+	maxTimeout := time.Duration(config.GetEnvInt("SHELL_JOB_MAX_TIMEOUT_SECONDS", 0)) * time.Second
+	if maxTimeout > 0 && timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	maxOutput := config.GetEnvInt("SHELL_JOB_MAX_OUTPUT_BYTES", DEFAULT_SHELL_MAX_OUTPUT)
+	if payload.MaxOutputBytes > 0 && payload.MaxOutputBytes < maxOutput {
+		maxOutput = payload.MaxOutputBytes
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := payload.Args
+	if payload.Shell {
+		args = append([]string{"-c", payload.Command}, payload.Args...)
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	// Start from an empty environment so gocrony's own secrets never leak
+	// into the child; only PATH is carried over.
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	for k, v := range payload.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdout := &cappedBuffer{limit: maxOutput}
+	stderr := &cappedBuffer{limit: maxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait forever on children that inherited the output pipes.
+	cmd.WaitDelay = 5 * time.Second
+
+	err = cmd.Run()
+	duration := time.Since(start).Milliseconds()
+
+	result := shellJobResult{
+		ExitCode:  -1,
+		Stdout:    stdout.buf.String(),
+		Stderr:    stderr.buf.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	out, _ := json.Marshal(result)
+
+	if err != nil {
+		response := string(out)
+		var exitErr *exec.ExitError
+		if ctx.Err() != nil {
+			response = fmt.Sprintf("command timed out after %s\n", timeout) + response
+		} else if !errors.As(err, &exitErr) {
+			response = err.Error() + "\n" + response
+		}
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), result.ExitCode, response, duration)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	w.logJobExecution(ctx, job.ID.String(), cmd.ProcessState.String(), result.ExitCode, string(out), duration)
+	w.updateJob(ctx, job, job.ID.String(), models.StatusPending)
+	return nil
+}
+
+// cappedBuffer keeps the first limit bytes written to it and silently drops
+// the rest so a chatty command can't exhaust memory.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	remaining := c.limit - c.buf.Len()
+	if remaining <= 0 {
+		c.truncated = c.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		c.buf.Write(p[:remaining])
+		c.truncated = true
+		return len(p), nil
+	}
+	return c.buf.Write(p)
+}
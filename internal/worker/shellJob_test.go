@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateShellEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"plain names", map[string]string{"REPORT_DATE": "2026-01-01", "lang": "en"}, false},
+		{"empty name", map[string]string{"": "x"}, true},
+		{"name with =", map[string]string{"A=B": "x"}, true},
+		{"name with a nul byte", map[string]string{"A\x00": "x"}, true},
+		{"PATH", map[string]string{"PATH": "/tmp"}, true},
+		{"blocked names ignore case", map[string]string{"bash_env": "/tmp/x"}, true},
+		{"IFS", map[string]string{"IFS": "/"}, true},
+		{"loader prefix", map[string]string{"LD_PRELOAD": "/tmp/x.so"}, true},
+		{"macOS loader prefix", map[string]string{"DYLD_INSERT_LIBRARIES": "/tmp/x"}, true},
+		{"exported bash function", map[string]string{"BASH_FUNC_ls%%": "() { id; }"}, true},
+	}
+	for _, tt := range tests {
+		err := validateShellEnv(tt.env)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateShellEnv error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShellDir(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "reports"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SHELL_JOBS_ALLOWED_DIRS", root)
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{"empty uses the worker's directory", "", "", false},
+		{"the allowed dir", root, resolvedRoot, false},
+		{"inside the allowed dir", filepath.Join(root, "reports"), filepath.Join(resolvedRoot, "reports"), false},
+		{"dot dot inside the allowed dir", filepath.Join(root, "reports", ".."), resolvedRoot, false},
+		{"relative", "reports", "", true},
+		{"outside", outside, "", true},
+		{"dot dot out of the allowed dir", filepath.Join(root, "..", filepath.Base(outside)), "", true},
+		{"symlink out of the allowed dir", filepath.Join(root, "escape"), "", true},
+		{"missing", filepath.Join(root, "missing"), "", true},
+	}
+	for _, tt := range tests {
+		got, err := shellDir(tt.dir)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: shellDir(%q) error = %v, wantErr %v", tt.name, tt.dir, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: shellDir(%q) = %q, want %q", tt.name, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestShellBinary(t *testing.T) {
+	bin := t.TempDir()
+	tool := filepath.Join(bin, "tool")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(tool, filepath.Join(bin, "alias")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("SHELL_JOBS_ENABLED", "true")
+	t.Setenv("SHELL_JOBS_ALLOWED_BINARIES", strings.Join([]string{"tool", tool}, ","))
+
+	tests := []struct {
+		name    string
+		payload ShellRequestPayload
+		want    string
+		wantErr bool
+	}{
+		{"allowed by path", ShellRequestPayload{Command: "tool"}, tool, false},
+		{"absolute command", ShellRequestPayload{Command: tool}, tool, false},
+		{"symlink to an allowed binary", ShellRequestPayload{Command: "alias"}, "", true},
+		{"not on PATH", ShellRequestPayload{Command: "missing"}, "", true},
+		{"shell is not allowed", ShellRequestPayload{Command: "tool", Shell: true}, "", true},
+		{"empty command", ShellRequestPayload{}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := shellBinary(&tt.payload)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: shellBinary error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: shellBinary = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	t.Setenv("SHELL_JOBS_ENABLED", "false")
+	if _, err := shellBinary(&ShellRequestPayload{Command: "tool"}); err == nil {
+		t.Error("shellBinary allowed a command with shell jobs disabled")
+	}
+}
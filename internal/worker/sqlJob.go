@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+const DEFAULT_SQL_MAX_ROWS = 100
+
+// MAX_LOGGED_RESPONSE caps the response stored with a run's log.
+const MAX_LOGGED_RESPONSE = 10 * 1024
+
+type SQLRequestPayload struct {
+	Query   string `json:"query"`
+	Args    []any  `json:"args,omitempty"`
+	MaxRows int    `json:"max_rows,omitempty"`
+}
+
+type sqlJobResult struct {
+	RowsAffected int64    `json:"rows_affected"`
+	Columns      []string `json:"columns,omitempty"`
+	Rows         [][]any  `json:"rows,omitempty"`
+	Truncated    bool     `json:"truncated,omitempty"`
+}
+
+func (w *Worker) executeSqlJob(ctx context.Context, job *models.Job) error {
+	start := time.Now()
+
+	var payload SQLRequestPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), int64(time.Since(start).Milliseconds()))
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	if payload.Query == "" {
+		err := fmt.Errorf("Query is required")
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), 0)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	if db.JobDB == nil {
+		err := fmt.Errorf("sql datasource not configured")
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), 0)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	if payload.MaxRows <= 0 {
+		payload.MaxRows = DEFAULT_SQL_MAX_ROWS
+	}
+
+	rows, err := db.JobDB.Query(ctx, payload.Query, payload.Args...)
+	if err != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), time.Since(start).Milliseconds())
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return err
+	}
+	defer rows.Close()
+
+	var result sqlJobResult
+	var scanErr error
+	for _, field := range rows.FieldDescriptions() {
+		result.Columns = append(result.Columns, field.Name)
+	}
+	// Keep draining past MaxRows so the command tag reports every row.
+	for rows.Next() {
+		if len(result.Rows) >= payload.MaxRows {
+			result.Truncated = true
+			continue
+		}
+		values, err := rows.Values()
+		if err != nil {
+			scanErr = err
+			break
+		}
+		for i, v := range values {
+			if id, ok := v.([16]byte); ok {
+				values[i] = uuid.UUID(id).String()
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	rows.Close()
+	duration := time.Since(start).Milliseconds()
+
+	if scanErr == nil {
+		scanErr = rows.Err()
+	}
+	if scanErr != nil {
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, scanErr.Error(), duration)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
+		return scanErr
+	}
+	tag := rows.CommandTag()
+	result.RowsAffected = tag.RowsAffected()
+
+	out := encodeSqlResult(&result)
+	w.logJobExecution(ctx, job.ID.String(), tag.String(), 0, string(out), duration)
+	w.updateJob(ctx, job, job.ID.String(), models.StatusPending)
+	return nil
+}
+
+// encodeSqlResult drops rows from the end until the result fits in
+// MAX_LOGGED_RESPONSE, so the logged result stays valid JSON.
+func encodeSqlResult(result *sqlJobResult) []byte {
+	out, _ := json.Marshal(result)
+	for len(out) > MAX_LOGGED_RESPONSE && len(result.Rows) > 0 {
+		keep := min(len(result.Rows)*MAX_LOGGED_RESPONSE/len(out), len(result.Rows)-1)
+		result.Rows = result.Rows[:keep]
+		result.Truncated = true
+		out, _ = json.Marshal(result)
+	}
+	return truncateUTF8(out, MAX_LOGGED_RESPONSE)
+}
+
+// truncateUTF8 cuts b to at most n bytes without splitting a rune, Postgres
+// rejects text that isn't valid UTF-8.
+func truncateUTF8(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	b = b[:n]
+	for i := 0; i < utf8.UTFMax && len(b) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size > 1 {
+			break
+		}
+		b = b[:len(b)-1]
+	}
+	return b
+}
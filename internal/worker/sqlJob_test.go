@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{"shorter than the limit", "abc", 10, "abc"},
+		{"exactly the limit", "abc", 3, "abc"},
+		{"ascii", "abcdef", 4, "abcd"},
+		{"cut inside a two byte rune", "aé", 2, "a"},
+		{"cut after a two byte rune", "aéb", 3, "aé"},
+		{"cut inside a four byte rune", "ab😀", 5, "ab"},
+		{"only a partial rune", "😀", 3, ""},
+		{"trailing invalid bytes are dropped", "ab\xff\xffcd", 4, "ab"},
+	}
+	for _, tt := range tests {
+		got := string(truncateUTF8([]byte(tt.in), tt.n))
+		if got != tt.want {
+			t.Errorf("%s: truncateUTF8(%q, %d) = %q, want %q", tt.name, tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeSqlResult(t *testing.T) {
+	row := []any{strings.Repeat("é", 100)}
+	tests := []struct {
+		name      string
+		rows      int
+		truncated bool
+	}{
+		{"small result", 3, false},
+		{"rows are dropped until it fits", 500, true},
+	}
+	for _, tt := range tests {
+		result := &sqlJobResult{Columns: []string{"name"}}
+		for i := 0; i < tt.rows; i++ {
+			result.Rows = append(result.Rows, row)
+		}
+		out := encodeSqlResult(result)
+		if len(out) > MAX_LOGGED_RESPONSE || !utf8.Valid(out) {
+			t.Errorf("%s: encoded %d bytes, valid UTF-8 %v", tt.name, len(out), utf8.Valid(out))
+		}
+		var decoded sqlJobResult
+		if err := json.Unmarshal(out, &decoded); err != nil {
+			t.Errorf("%s: result is not valid JSON: %v", tt.name, err)
+			continue
+		}
+		if decoded.Truncated != tt.truncated || (!tt.truncated && len(decoded.Rows) != tt.rows) {
+			t.Errorf("%s: truncated = %v with %d rows", tt.name, decoded.Truncated, len(decoded.Rows))
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/secrets"
+)
+
+// MAX_TEMPLATE_OUTPUT caps what one payload string may render to, and every
+// string a template function returns on the way.
+const MAX_TEMPLATE_OUTPUT = 64 * 1024
+
+var errTemplateTooLarge = fmt.Errorf("template output exceeds %d bytes", MAX_TEMPLATE_OUTPUT)
+
+// limitedWriter fails the template as soon as its output passes
+// MAX_TEMPLATE_OUTPUT.
+type limitedWriter struct {
+	strings.Builder
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.Len()+len(p) > MAX_TEMPLATE_OUTPUT {
+		return 0, errTemplateTooLarge
+	}
+	return w.Builder.Write(p)
+}
+
+func boundedString(s string) (string, error) {
+	if len(s) > MAX_TEMPLATE_OUTPUT {
+		return "", errTemplateTooLarge
+	}
+	return s, nil
+}
+
+// TemplateData is what payload templates see, e.g.
+// {{ formatDate (addDays .ScheduledAt -1) "2006-01-02" }}.
+type TemplateData struct {
+	JobID       string
+	JobName     string
+	RunID       string
+	Attempt     int
+	ScheduledAt time.Time
+	FiredAt     time.Time
+	Timezone    string
+
+	jobID          string
+	previousStatus *string
+}
+
+// PreviousStatus is the status of the last recorded run, loaded on first use.
+func (d *TemplateData) PreviousStatus() string {
+	if d.previousStatus == nil {
+		var last models.Logs
+		status := ""
+		if err := db.DB.Where("job_id = ?", d.jobID).Order("run_at DESC").First(&last).Error; err == nil {
+			status = last.Status
+		}
+		d.previousStatus = &status
+	}
+	return *d.previousStatus
+}
+
+// secretFuncs returns the helpers every payload can use, templated or not:
+// secret, and bounded copies of the builtins that build strings. secret
+// appends every value it resolves to used so the caller can redact it.
+func secretFuncs(job *models.Job, used *[]string) template.FuncMap {
+	return template.FuncMap{
+		"secret": func(name string) (string, error) {
+			value, err := secrets.Lookup(job.UserID, name)
+			if err != nil {
+				return "", err
+			}
+			*used = append(*used, value)
+			return value, nil
+		},
+		// The builtins that build strings are replaced by bounded copies so
+		// repeated assignments can't grow a value past the output cap.
+		"print": func(args ...any) (string, error) {
+			return boundedString(fmt.Sprint(args...))
+		},
+		"printf": func(format string, args ...any) (string, error) {
+			return boundedString(fmt.Sprintf(format, args...))
+		},
+		"println": func(args ...any) (string, error) {
+			return boundedString(fmt.Sprintln(args...))
+		},
+		"html": func(args ...any) (string, error) {
+			return boundedString(template.HTMLEscaper(args...))
+		},
+		"js": func(args ...any) (string, error) {
+			return boundedString(template.JSEscaper(args...))
+		},
+		"urlquery": func(args ...any) (string, error) {
+			return boundedString(template.URLQueryEscaper(args...))
+		},
+	}
+}
+
+// templateFuncs adds the date helpers templated payloads get on top of
+// secretFuncs. Dates are formatted in loc, the job's timezone.
+func templateFuncs(job *models.Job, loc *time.Location, used *[]string) template.FuncMap {
+	funcs := secretFuncs(job, used)
+	funcs["formatDate"] = func(t time.Time, layout string) string {
+		return t.In(loc).Format(layout)
+	}
+	funcs["addDays"] = func(t time.Time, days int) time.Time {
+		return t.In(loc).AddDate(0, 0, days)
+	}
+	funcs["addDuration"] = func(t time.Time, duration string) (time.Time, error) {
+		d, err := time.ParseDuration(duration)
+		return t.Add(d), err
+	}
+	funcs["startOfDay"] = func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	funcs["unix"] = func(t time.Time) int64 {
+		return t.Unix()
+	}
+	return funcs
+}
+
+// parseTemplate parses a payload string. Loops and nested templates are
+// refused: without them a template does work in proportion to its length,
+// which matters because execution can't be cancelled.
+func parseTemplate(s string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New("payload").Option("missingkey=error").Funcs(funcs).Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("define and block are not supported")
+	}
+	if err := checkNodes(tmpl.Tree.Root); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func checkNodes(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkNodes(child); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		return errors.New("range is not supported")
+	case *parse.TemplateNode:
+		return errors.New("template is not supported")
+	}
+	return nil
+}
+
+func checkBranch(n *parse.BranchNode) error {
+	if err := checkNodes(n.List); err != nil {
+		return err
+	}
+	return checkNodes(n.ElseList)
+}
+
+// renderPayload executes the templates in every string of a job's payload
+// and returns the rendered payload with the secret values it resolved.
+// Payloads of jobs that aren't templated can only reference secrets.
+func renderPayload(job *models.Job, r *run) (json.RawMessage, []string, error) {
+	if !bytes.Contains(job.Payload, []byte("{{")) {
+		return job.Payload, nil, nil
+	}
+
+	var used []string
+	if !job.Templated {
+		return executePayload(job.Payload, secretFuncs(job, &used), nil, &used)
+	}
+
+	loc, err := time.LoadLocation(job.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	data := &TemplateData{
+		JobID:       job.ID.String(),
+		JobName:     job.Name,
+		RunID:       r.ID.String(),
+		Attempt:     r.Attempt,
+		ScheduledAt: r.ScheduledAt.In(loc),
+		FiredAt:     r.FiredAt.In(loc),
+		Timezone:    loc.String(),
+		jobID:       job.ID.String(),
+	}
+	return executePayload(job.Payload, templateFuncs(job, loc, &used), data, &used)
+}
+
+func executePayload(payload json.RawMessage, funcs template.FuncMap, data any, used *[]string) (json.RawMessage, []string, error) {
+	rendered, err := mapPayloadStrings(payload, func(s string) (string, error) {
+		tmpl, err := parseTemplate(s, funcs)
+		if err != nil {
+			return "", err
+		}
+		var out limitedWriter
+		if err := tmpl.Execute(&out, data); err != nil {
+			return "", err
+		}
+		return out.String(), nil
+	})
+	return rendered, *used, err
+}
+
+// validateTemplates parses every template in a payload without executing it.
+// Payloads that aren't templated may only use secretFuncs.
+func validateTemplates(payload json.RawMessage, templated bool) error {
+	if !bytes.Contains(payload, []byte("{{")) {
+		return nil
+	}
+	var used []string
+	funcs := secretFuncs(&models.Job{}, &used)
+	if templated {
+		funcs = templateFuncs(&models.Job{}, time.UTC, &used)
+	}
+	_, err := mapPayloadStrings(payload, func(s string) (string, error) {
+		_, err := parseTemplate(s, funcs)
+		return s, err
+	})
+	return err
+}
+
+// mapPayloadStrings applies fn to every string containing "{{" in a JSON
+// document. Working on decoded strings rather than the raw JSON lets quotes
+// inside {{ }} work, and re-encoding escapes whatever fn produced.
+func mapPayloadStrings(payload json.RawMessage, fn func(string) (string, error)) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var walk func(v any) (any, error)
+	walk = func(v any) (any, error) {
+		switch value := v.(type) {
+		case string:
+			if !strings.Contains(value, "{{") {
+				return value, nil
+			}
+			return fn(value)
+		case map[string]any:
+			for k, item := range value {
+				out, err := walk(item)
+				if err != nil {
+					return nil, err
+				}
+				value[k] = out
+			}
+		case []any:
+			for i, item := range value {
+				out, err := walk(item)
+				if err != nil {
+					return nil, err
+				}
+				value[i] = out
+			}
+		}
+		return v, nil
+	}
+	doc, err := walk(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(out.Bytes()), nil
+}
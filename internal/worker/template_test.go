@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestRenderPayload(t *testing.T) {
+	r := &run{
+		ID:          uuid.MustParse("0b8f3c8e-2d7a-4a51-9d3e-6f1c2a7b9e40"),
+		ScheduledAt: time.Date(2026, 3, 1, 23, 30, 0, 0, time.UTC),
+		FiredAt:     time.Date(2026, 3, 1, 23, 30, 2, 0, time.UTC),
+		Attempt:     2,
+	}
+	tests := []struct {
+		name      string
+		payload   string
+		templated bool
+		want      string
+		wantErr   bool
+	}{
+		{"no templates", `{"url":"https://example.com"}`, true, `{"url":"https://example.com"}`, false},
+		{"job and run fields", `{"body":"{{ .JobName }} #{{ .Attempt }} {{ .RunID }}"}`, true, `{"body":"nightly #2 0b8f3c8e-2d7a-4a51-9d3e-6f1c2a7b9e40"}`, false},
+		{"dates use the job timezone", `{"day":"{{ formatDate .ScheduledAt \"2006-01-02 15:04\" }}"}`, true, `{"day":"2026-03-02 08:30"}`, false},
+		{"date helpers", `{"day":"{{ formatDate (addDays (startOfDay .ScheduledAt) -1) \"2006-01-02T15:04\" }}"}`, true, `{"day":"2026-03-01T00:00"}`, false},
+		{"unix", `{"ts":"{{ unix .FiredAt }}"}`, true, `{"ts":"1772407802"}`, false},
+		{"output is JSON escaped", `{"body":"{{ printf \"%q\" .JobName }}"}`, true, `{"body":"\"nightly\""}`, false},
+		{"nested values", `{"a":[{"b":"{{ .Timezone }}"}],"n":1.50}`, true, `{"a":[{"b":"Asia/Tokyo"}],"n":1.50}`, false},
+		{"unknown field", `{"body":"{{ .Missing }}"}`, true, "", true},
+		{"range is refused", `{"body":"{{ range .JobName }}x{{ end }}"}`, true, "", true},
+		{"define is refused", `{"body":"{{ define \"x\" }}y{{ end }}"}`, true, "", true},
+		{"output over the cap", `{"body":"{{ $s := printf \"%65537s\" \"\" }}{{ $s }}"}`, true, "", true},
+		{"untemplated without references", `{"body":"{ {not a template} }"}`, false, `{"body":"{ {not a template} }"}`, false},
+		{"untemplated has no job fields", `{"body":"{{ .JobName }}"}`, false, "", true},
+		{"untemplated has no date helpers", `{"body":"{{ unix 0 }}"}`, false, "", true},
+	}
+	for _, tt := range tests {
+		job := &models.Job{
+			ID:        uuid.New(),
+			Name:      "nightly",
+			Timezone:  "Asia/Tokyo",
+			Templated: tt.templated,
+			Payload:   json.RawMessage(tt.payload),
+		}
+		got, used, err := renderPayload(job, r)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: renderPayload error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(got) != tt.want {
+			t.Errorf("%s: renderPayload = %s, want %s", tt.name, got, tt.want)
+		}
+		if len(used) != 0 {
+			t.Errorf("%s: resolved secrets %q without any reference", tt.name, used)
+		}
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	tests := []struct {
+		name      string
+		payload   string
+		templated bool
+		wantErr   bool
+	}{
+		{"no templates", `{"a":"b"}`, false, false},
+		{"secret reference", `{"a":"{{ secret \"token\" }}"}`, false, false},
+		{"secret reference when templated", `{"a":"Bearer {{ secret \"token\" }}"}`, true, false},
+		{"date helper when templated", `{"a":"{{ formatDate .FiredAt \"2006\" }}"}`, true, false},
+		{"date helper when not templated", `{"a":"{{ formatDate .FiredAt \"2006\" }}"}`, false, true},
+		{"syntax error", `{"a":"{{ .JobName "}`, true, true},
+		{"unknown function", `{"a":"{{ exec \"id\" }}"}`, true, true},
+		{"range", `{"a":"{{ range .JobName }}{{ end }}"}`, true, true},
+		{"block", `{"a":"{{ block \"x\" . }}{{ end }}"}`, true, true},
+		{"template", `{"a":"{{ template \"payload\" }}"}`, true, true},
+		{"range inside if", `{"a":"{{ if true }}{{ range .JobName }}{{ end }}{{ end }}"}`, true, true},
+		{"invalid JSON", `{"a":"{{"`, true, true},
+	}
+	for _, tt := range tests {
+		err := validateTemplates(json.RawMessage(tt.payload), tt.templated)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateTemplates error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var w limitedWriter
+	if _, err := w.Write([]byte(strings.Repeat("a", MAX_TEMPLATE_OUTPUT))); err != nil {
+		t.Fatalf("Write up to the cap: %v", err)
+	}
+	if _, err := w.Write([]byte("a")); err != errTemplateTooLarge {
+		t.Fatalf("Write past the cap = %v, want errTemplateTooLarge", err)
+	}
+}
@@ -3,172 +3,334 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/akhilbisht798/gocrony/config"
 	"github.com/akhilbisht798/gocrony/internal/cache"
 	"github.com/akhilbisht798/gocrony/internal/db"
 	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/notify"
 	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/akhilbisht798/gocrony/internal/secrets"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 )
 
-const MAX_RETRY = 3
+const (
+	DEFAULT_JOB_TIMEOUT = 5 * time.Minute
+	MAX_JOB_TIMEOUT     = 1 * time.Hour
+)
+
+// WEBHOOK_TIMEOUT bounds a single webhook delivery attempt.
+const WEBHOOK_TIMEOUT = 30 * time.Second
+
+// NOTIFY_TIMEOUT bounds waking the scheduler and sending the alerts after a
+// run, which no longer live on the run's own deadline.
+const NOTIFY_TIMEOUT = 30 * time.Second
+
+// POP_TIMEOUT bounds each blocking pop so a worker notices cancellation and
+// a dead redis connection instead of blocking forever.
+const POP_TIMEOUT = 5 * time.Second
 
 type Worker struct {
 	ID     string
 	client *http.Client
+	// slots is shared by every worker in a pool and caps concurrent executions.
+	slots chan struct{}
 }
 
-func NewWorker(id string) *Worker {
+func NewWorker(id string, slots chan struct{}) *Worker {
 	return &Worker{
 		ID:     id,
 		client: &http.Client{},
+		slots:  slots,
+	}
+}
+
+// DefaultJobTimeout applies to jobs without timeout_seconds, it is read from
+// JOB_DEFAULT_TIMEOUT_SECONDS.
+func DefaultJobTimeout() time.Duration {
+	seconds := config.GetEnvInt("JOB_DEFAULT_TIMEOUT_SECONDS", 0)
+	if seconds <= 0 {
+		return DEFAULT_JOB_TIMEOUT
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// MaxJobTimeout is the largest timeout_seconds a job may ask for, it is read
+// from JOB_MAX_TIMEOUT_SECONDS.
+func MaxJobTimeout() time.Duration {
+	seconds := config.GetEnvInt("JOB_MAX_TIMEOUT_SECONDS", 0)
+	if seconds <= 0 {
+		return MAX_JOB_TIMEOUT
 	}
+	return time.Duration(seconds) * time.Second
+}
+
+// JobTimeout is the execution deadline for job, clamped to MaxJobTimeout in
+// case the maximum was lowered after the job was created.
+func JobTimeout(job *models.Job) time.Duration {
+	maxTimeout := MaxJobTimeout()
+	timeout := DefaultJobTimeout()
+	if job.TimeoutSeconds > 0 {
+		// Capped before converting so a huge value can't overflow.
+		timeout = time.Duration(min(job.TimeoutSeconds, int(maxTimeout/time.Second))) * time.Second
+	}
+	return min(timeout, maxTimeout)
 }
 
 func (w *Worker) Start(ctx context.Context) {
 	for {
+		// Wait for a free slot before popping so saturated workers leave jobs
+		// in redis for other processes to pick up.
 		select {
 		case <-ctx.Done():
 			log.Println("Worker stop due to context cancellation", w.ID)
 			return
-		default:
+		case w.slots <- struct{}{}:
 		}
-		//TODO: change in future to check redis is working or nnot
-		// otherwise this will be blocked forever.
-		vals, err := cache.Rbd.BRPop(ctx, 0, scheduler.QUEUE).Result()
+
+		// BLMOVE keeps the entry in PROCESSING_QUEUE until it is acked, so a
+		// crash mid-execution leaves it there for redelivery.
+		jobId, err := cache.Rbd.BLMove(ctx, scheduler.QUEUE, scheduler.PROCESSING_QUEUE, "RIGHT", "LEFT", POP_TIMEOUT).Result()
 		if err != nil {
+			<-w.slots
+			if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+				continue
+			}
 			log.Println("Error poping from queue ", err.Error())
 			time.Sleep(1 * time.Second)
 			continue
 		}
-
-		if len(vals) < 2 {
-			continue
+		if err := touchInflight(ctx, jobId); err != nil {
+			log.Printf("Worker %s: unable to mark %s in flight: %v", w.ID, jobId, err)
 		}
 
-		jobId := vals[1]
-		go w.executeJobWithTimeout(jobId)
+		// jobId keeps its delivery token for the visibility bookkeeping, the
+		// executor only needs the entry itself.
+		go func() {
+			defer func() { <-w.slots }()
+			stop := heartbeat(jobId)
+			w.executeJobWithTimeout(scheduler.EntryName(jobId))
+			stop()
+			if err := ackJob(jobId); err != nil {
+				log.Printf("Worker %s: unable to ack %s: %v", w.ID, jobId, err)
+			}
+		}()
 	}
 }
 
-func (w *Worker) executeJobWithTimeout(jobId string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-
-	done := make(chan error, 1)
-
-	go func() {
-		done <- w.executeJob(ctx, jobId)
-	}()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			log.Printf("Worker %s: job execution failed for %s: %v", w.ID, jobId, err)
-		} else {
-			log.Printf("Worker %s: job execution successfull for %s", w.ID, jobId)
+func (w *Worker) executeJobWithTimeout(entry string) {
+	if nodeRunId, ok := strings.CutPrefix(entry, scheduler.WORKFLOW_PREFIX); ok {
+		w.executeWorkflowNode(nodeRunId)
+		return
+	}
+	if deliveryId, ok := strings.CutPrefix(entry, scheduler.WEBHOOK_PREFIX); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), WEBHOOK_TIMEOUT)
+		defer cancel()
+		if err := notify.Deliver(ctx, w.client, deliveryId); err != nil {
+			log.Printf("Worker %s: webhook delivery %s failed: %v", w.ID, deliveryId, err)
 		}
-	case <-ctx.Done():
-		log.Printf("Worker %s: Job %s timed out", w.ID, jobId)
-		w.logJobExecution(jobId, string(models.StatusFailed), 0, "Job failed timeout", 0)
-		w.updateJob(nil, jobId, models.StatusFailed)
+		return
 	}
-}
 
-// Instead of returing error save the logs.
-func (w *Worker) executeJob(ctx context.Context, jobId string) error {
+	jobId := entry
 	var job models.Job
-	txn := db.DB.Where("id = ?", jobId).First(&job)
-	if txn.Error != nil {
-		return fmt.Errorf("Error: Job not found %s", txn.Error.Error())
-	}
-	if job.Retry > MAX_RETRY {
-		w.updateJob(&job, job.ID.String(), models.StatusAborted)
-		return fmt.Errorf("Error: Job aborted %s due to max retry", jobId)
+	if err := db.DB.Where("id = ?", jobId).First(&job).Error; err != nil {
+		log.Printf("Worker %s: job not found %s: %v", w.ID, jobId, err)
+		return
 	}
-	switch job.Type {
-	case models.JobTypeHTTP:
-		w.executeHttpJob(ctx, &job)
-	default:
-		log.Println("Doesn't support this type right now.")
+	// The scheduler marks a job queued before pushing it. Anything else means
+	// this entry is a redelivered or duplicate copy of a run that already
+	// finished or was rescheduled, so it is only acknowledged.
+	if job.Status != models.StatusQueued {
+		log.Printf("Worker %s: skipping job %s, status is %q not queued", w.ID, jobId, job.Status)
+		return
 	}
-	return nil
-}
 
-type HTTPRequestPayload struct {
-	URL     string            `json:"url"`
-	Method  string            `json:"method"`
-	Headers map[string]string `json:"headers,omitempty"`
-	Body    string            `json:"body,omitempty"`
+	r := &run{
+		ID:      uuid.New(),
+		FiredAt: time.Now().UTC(),
+		Attempt: job.Retry + 1,
+	}
+	// next_run is only moved once the run finishes, so it still holds the
+	// slot this run was scheduled for.
+	if job.NextRun != nil {
+		r.ScheduledAt = job.NextRun.UTC()
+	}
+	w.runWithTimeout(&job, r)
 }
 
-func (w *Worker) executeHttpJob(ctx context.Context, job *models.Job) error {
-	start := time.Now()
+func (w *Worker) runWithTimeout(job *models.Job, r *run) {
+	runCtx, cancelRun := context.WithCancelCause(withRun(context.Background(), r))
+	defer cancelRun(nil)
 
-	var payload HTTPRequestPayload
-	if err := json.Unmarshal(job.Payload, &payload); err != nil {
-		w.logJobExecution(job.ID.String(), string(models.StatusFailed), 0, err.Error(), int64(time.Since(start).Milliseconds()))
-		w.updateJob(job, job.ID.String(), models.StatusFailed)
-		return err
+	ok, err := acquireRunLock(runCtx, job, r)
+	if err != nil {
+		w.skipRun(runCtx, job, "unable to apply concurrency policy: "+err.Error())
+		return
 	}
-	if payload.URL == "" {
-		return fmt.Errorf("Url is required")
+	if !ok {
+		w.skipRun(runCtx, job, "previous run still in progress")
+		return
 	}
-	if payload.Method == "" {
-		payload.Method = "GET"
+	defer releaseRunLock(job, r)
+	defer trackRun(r, cancelRun)()
+
+	timeout := JobTimeout(job)
+	ctx, cancel := context.WithTimeout(runCtx, timeout)
+	defer cancel()
+
+	// Every executor honours ctx, so a timed out job records its own failure
+	// and goes through the normal retry path.
+	err = w.executeJob(ctx, job)
+	switch {
+	case isReplaced(ctx):
+		log.Printf("Worker %s: Job %s run %s replaced by a newer run", w.ID, job.ID, r.ID)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Printf("Worker %s: Job %s timed out after %s", w.ID, job.ID, timeout)
+	case err != nil:
+		log.Printf("Worker %s: job execution failed for %s: %v", w.ID, job.ID, err)
+	default:
+		log.Printf("Worker %s: job execution successfull for %s", w.ID, job.ID)
+	}
+}
+
+// Instead of returing error save the logs.
+func (w *Worker) executeJob(ctx context.Context, job *models.Job) error {
+	r := runFromContext(ctx)
+	if r.WorkflowNodeRunID == uuid.Nil && job.Retry >= job.RetryPolicy.WithDefaults().MaxAttempts {
+		w.updateJob(ctx, job, job.ID.String(), models.StatusAborted)
+		return fmt.Errorf("Error: Job aborted %s due to max retry", job.ID)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, payload.Method, payload.URL, strings.NewReader(payload.Body))
+	// The payload is rendered on a copy so resolved secrets only live for
+	// this execution and are never written back with the job.
+	payload, values, err := renderPayload(job, r)
 	if err != nil {
-		w.logJobExecution(job.ID.String(), string(models.StatusFailed), 0, err.Error(), int64(time.Since(start).Milliseconds()))
-		w.updateJob(job, job.ID.String(), models.StatusFailed)
+		err = fmt.Errorf("resolving payload: %w", err)
+		w.logJobExecution(ctx, job.ID.String(), string(models.StatusFailed), 0, err.Error(), 0)
+		w.updateJob(ctx, job, job.ID.String(), models.StatusFailed)
 		return err
 	}
+	ctx = secrets.WithRedaction(ctx, values)
+	resolved := *job
+	resolved.Payload = payload
 
-	for k, v := range payload.Headers {
-		req.Header.Set(k, v)
+	// Errors can quote the resolved payload and end up on stdout.
+	switch job.Type {
+	case models.JobTypeHTTP:
+		err = w.executeHttpJob(ctx, &resolved)
+	case models.JobTypeSQL:
+		err = w.executeSqlJob(ctx, &resolved)
+	case models.JobTypeQueue:
+		err = w.executeQueueJob(ctx, &resolved)
+	case models.JobTypeShell:
+		err = w.executeShellJob(ctx, &resolved)
+	default:
+		log.Println("Doesn't support this type right now.")
 	}
-	resp, err := w.client.Do(req)
-	duration := time.Since(start).Milliseconds()
+	return secrets.RedactError(ctx, err)
+}
 
-	if err != nil {
-		w.logJobExecution(job.ID.String(), string(models.StatusFailed), 0, err.Error(), duration)
-		w.updateJob(job, job.ID.String(), models.StatusFailed)
-		return err
+// ValidatePayload rejects payloads that could never run so the mistake is
+// reported at creation instead of on every execution. Payloads that aren't
+// templated may still reference secrets, but nothing else.
+func ValidatePayload(jobType models.JobType, raw json.RawMessage, templated bool) error {
+	if err := validateTemplates(raw, templated); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	switch jobType {
+	case models.JobTypeHTTP:
+		var payload HTTPRequestPayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return err
+		}
+		if payload.URL == "" {
+			return fmt.Errorf("Url is required")
+		}
+		if err := payload.Auth.validate(); err != nil {
+			return err
+		}
+		return payload.Assertions.validate()
+	case models.JobTypeSQL:
+		var payload SQLRequestPayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return err
+		}
+		if payload.Query == "" {
+			return fmt.Errorf("Query is required")
+		}
+	case models.JobTypeQueue:
+		var payload QueueRequestPayload
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return err
+		}
+		return validateQueuePayload(&payload)
+	case models.JobTypeShell:
+		return ValidateShellPayload(raw)
 	}
-	defer resp.Body.Close()
-	bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 10*1024))
-	w.logJobExecution(job.ID.String(), resp.Status, resp.StatusCode, string(bodyBytes), duration)
-	w.updateJob(job, job.ID.String(), models.StatusPending) // Pending means ready to run again.
 	return nil
 }
 
-func (w *Worker) logJobExecution(jobId string, status string, statusCode int, Response string, duration int64) {
+// logJobExecution records a run. Secret values resolved for the run are
+// redacted from the response before it is stored.
+func (w *Worker) logJobExecution(ctx context.Context, jobId string, status string, statusCode int, Response string, duration int64) {
 	jobUuid, err := uuid.Parse(jobId)
 	if err != nil {
 		log.Println("Invalid jobId")
 		return
 	}
+	// Executors report a replaced run as a cancelled failure.
+	if isReplaced(ctx) {
+		status = models.LogStatusReplaced
+	}
 	logEntry := models.Logs{
+		RunAt:      time.Now().UTC(),
 		Status:     status,
 		StatusCode: statusCode,
-		Response:   Response,
+		Response:   secrets.Redact(ctx, Response),
 		JobID:      jobUuid,
 		Duration:   duration,
 	}
+	r := runFromContext(ctx)
+	if r != nil {
+		logEntry.RunID = r.ID
+		logEntry.RunAt = r.FiredAt
+	}
 	if err := db.DB.Create(&logEntry).Error; err != nil {
 		log.Println("Error: creating log for jobId", jobId)
 		return
 	}
+	if r != nil {
+		r.Log = &logEntry
+	}
+}
+
+func (w *Worker) updateJob(ctx context.Context, job *models.Job, jobId string, status models.StatusType) {
+	w.updateJobWithRetryAt(ctx, job, jobId, status, nil)
 }
 
-func (w *Worker) updateJob(job *models.Job, jobId string, status models.StatusType) {
+// updateJobWithRetryAt is updateJob for failures where the target told us
+// when to come back; retryAt replaces the retry policy delay.
+//
+// Runs that belong to a workflow leave the job's own schedule alone and report
+// their outcome to the workflow run instead.
+func (w *Worker) updateJobWithRetryAt(ctx context.Context, job *models.Job, jobId string, status models.StatusType, retryAt *time.Time) {
+	if r := runFromContext(ctx); r != nil && r.WorkflowNodeRunID != uuid.Nil {
+		w.finishWorkflowNode(r, status)
+		return
+	}
+	// The run that replaced this one owns the schedule now.
+	if isReplaced(ctx) {
+		return
+	}
+
 	var updatedJob models.Job
 	if job == nil {
 		if err := db.DB.Where("id = ?", jobId).First(&updatedJob).Error; err != nil {
@@ -179,32 +341,102 @@ func (w *Worker) updateJob(job *models.Job, jobId string, status models.StatusTy
 		updatedJob = *job
 	}
 
+	previousRetry := updatedJob.Retry
 	now := time.Now().UTC()
 	updatedJob.Status = status
 	updatedJob.LastRun = &now
 
 	if status == models.StatusPending {
-		updatedJob.NextRun, _ = scheduler.GetNextRun(job.Schedule, job.Timezone)
+		after := now
+		// Catching up moves to the occurrence after the one that ran, the
+		// scheduler picks it up right away while it is still in the past.
+		// Jittered runs do the same, counting from now could jump over an
+		// occurrence that came due while the delayed run executed.
+		followsSlot := updatedJob.MisfirePolicy == models.MisfireRunAll || updatedJob.JitterSeconds > 0
+		if r := runFromContext(ctx); r != nil && followsSlot && updatedJob.ScheduleKind != models.ScheduleFixedDelay && !r.ScheduledAt.IsZero() && previousRetry == 0 {
+			after = r.ScheduledAt
+		}
+		updatedJob.NextRun, _ = scheduler.NextOccurrence(&updatedJob, after)
+		if updatedJob.OneTime() {
+			updatedJob.Status = models.StatusCompleted
+		}
 		updatedJob.Retry = 0
 	}
 	if status == models.StatusFailed {
 		updatedJob.Retry = updatedJob.Retry + 1
-		if updatedJob.Retry < MAX_RETRY {
-			var retryAt time.Time
-			if updatedJob.Retry == 1 {
-				retryAt = time.Now().UTC().Add(1 * time.Minute)
-			} else {
-				delay := time.Duration(1<<updatedJob.Retry) * time.Minute
-				retryAt = time.Now().UTC().Add(delay)
+		if updatedJob.Retry < updatedJob.RetryPolicy.WithDefaults().MaxAttempts {
+			if retryAt == nil {
+				next := scheduler.GetRetryAt(updatedJob.RetryPolicy, updatedJob.Retry, now)
+				retryAt = &next
 			}
-			updatedJob.NextRun = &retryAt
+			updatedJob.NextRun = retryAt
 		} else {
 			updatedJob.Status = models.StatusAborted
 		}
 	}
 
-	if err := db.DB.Save(updatedJob).Error; err != nil {
-		log.Printf("Error Updating the job %s: %v", job.ID, err)
+	// Only the scheduling columns are written: the job passed in may carry a
+	// resolved payload, and the rest may have been edited meanwhile.
+	updates := map[string]any{
+		"status":   updatedJob.Status,
+		"last_run": updatedJob.LastRun,
+		"next_run": updatedJob.NextRun,
+		"retry":    updatedJob.Retry,
+	}
+	if err := db.DB.Model(&models.Job{}).Where("id = ?", updatedJob.ID).Updates(updates).Error; err != nil {
+		log.Printf("Error Updating the job %s: %v", updatedJob.ID, err)
 		return
 	}
+	// The run's ctx is already done when it timed out, which is the abort
+	// most worth alerting on.
+	notifyCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), NOTIFY_TIMEOUT)
+	defer cancel()
+	scheduler.Wake(notifyCtx)
+	w.notifyOutcome(notifyCtx, &updatedJob, previousRetry)
+}
+
+// notifyOutcome publishes the run's outcome to the owner's webhooks and email
+// alerts. A success after failed attempts is also reported as a recovery.
+func (w *Worker) notifyOutcome(ctx context.Context, job *models.Job, previousRetry int) {
+	var events []models.WebhookEvent
+	switch job.Status {
+	case models.StatusPending, models.StatusCompleted:
+		events = append(events, models.EventSuccess)
+		if previousRetry > 0 {
+			events = append(events, models.EventRecovery)
+		}
+	case models.StatusFailed:
+		events = append(events, models.EventFailure)
+	case models.StatusAborted:
+		events = append(events, models.EventAbort)
+	}
+
+	event := notify.RunEvent{
+		Job: notify.EventJob{
+			ID:      job.ID,
+			Name:    job.Name,
+			Type:    job.Type,
+			Status:  job.Status,
+			NextRun: job.NextRun,
+		},
+	}
+	if r := runFromContext(ctx); r != nil {
+		event.Run = notify.EventRun{
+			ID:          r.ID,
+			ScheduledAt: r.ScheduledAt,
+			FiredAt:     r.FiredAt,
+			Attempt:     r.Attempt,
+		}
+		if r.Log != nil {
+			event.Run.Status = r.Log.Status
+			event.Run.StatusCode = r.Log.StatusCode
+			event.Run.DurationMs = r.Log.Duration
+			event.Run.Response = r.Log.Response
+		}
+	}
+	for _, e := range events {
+		event.Event = e
+		notify.Publish(ctx, job.UserID, event)
+		notify.EmailAlert(ctx, job, event, previousRetry)
+	}
 }
@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/models"
+)
+
+func TestJobTimeout(t *testing.T) {
+	t.Setenv("JOB_DEFAULT_TIMEOUT_SECONDS", "")
+	t.Setenv("JOB_MAX_TIMEOUT_SECONDS", "")
+	tests := []struct {
+		name    string
+		seconds int
+		want    time.Duration
+	}{
+		{"server default", 0, DEFAULT_JOB_TIMEOUT},
+		{"job timeout", 30, 30 * time.Second},
+		{"capped at the server max", 7200, MAX_JOB_TIMEOUT},
+		{"too large for a duration", math.MaxInt, MAX_JOB_TIMEOUT},
+	}
+	for _, tt := range tests {
+		if got := JobTimeout(&models.Job{TimeoutSeconds: tt.seconds}); got != tt.want {
+			t.Errorf("%s: JobTimeout = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/akhilbisht798/gocrony/internal/db"
+	"github.com/akhilbisht798/gocrony/internal/models"
+	"github.com/akhilbisht798/gocrony/internal/scheduler"
+	"github.com/google/uuid"
+)
+
+// executeWorkflowNode runs the job behind a workflow node. Node runs get a
+// single attempt; the job's own retry policy and schedule are not involved.
+func (w *Worker) executeWorkflowNode(nodeRunId string) {
+	var nodeRun models.WorkflowNodeRun
+	if err := db.DB.Where("id = ?", nodeRunId).First(&nodeRun).Error; err != nil {
+		log.Printf("Worker %s: workflow node run not found %s: %v", w.ID, nodeRunId, err)
+		return
+	}
+	// A redelivered entry may find the node already finished.
+	if nodeRun.Status != models.NodeQueued && nodeRun.Status != models.NodeRunning {
+		return
+	}
+
+	var job models.Job
+	if err := db.DB.Where("id = ?", nodeRun.JobID).First(&job).Error; err != nil {
+		log.Printf("Worker %s: job not found %s: %v", w.ID, nodeRun.JobID, err)
+		w.finishWorkflowNode(&run{WorkflowNodeRunID: nodeRun.ID}, models.StatusFailed)
+		return
+	}
+
+	now := time.Now().UTC()
+	r := &run{
+		ID:                uuid.New(),
+		ScheduledAt:       now,
+		FiredAt:           now,
+		Attempt:           1,
+		WorkflowNodeRunID: nodeRun.ID,
+	}
+	err := db.DB.Model(&nodeRun).Updates(map[string]any{
+		"status":     models.NodeRunning,
+		"run_id":     r.ID,
+		"started_at": now,
+	}).Error
+	if err != nil {
+		log.Printf("Worker %s: unable to start workflow node %s: %v", w.ID, nodeRunId, err)
+		return
+	}
+	w.runWithTimeout(&job, r)
+}
+
+// finishWorkflowNode stores the outcome of a node run and lets the workflow
+// run move on to the nodes that depended on it.
+func (w *Worker) finishWorkflowNode(r *run, status models.StatusType) {
+	nodeStatus := models.NodeFailed
+	if status == models.StatusPending {
+		nodeStatus = models.NodeSucceeded
+	}
+
+	var nodeRun models.WorkflowNodeRun
+	if err := db.DB.Where("id = ?", r.WorkflowNodeRunID).First(&nodeRun).Error; err != nil {
+		log.Printf("Worker %s: workflow node run not found %s: %v", w.ID, r.WorkflowNodeRunID, err)
+		return
+	}
+	err := db.DB.Model(&nodeRun).Updates(map[string]any{
+		"status":      nodeStatus,
+		"finished_at": time.Now().UTC(),
+	}).Error
+	if err != nil {
+		log.Printf("Worker %s: unable to finish workflow node %s: %v", w.ID, nodeRun.ID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := scheduler.AdvanceWorkflowRun(ctx, nodeRun.WorkflowRunID.String()); err != nil {
+		log.Printf("Worker %s: unable to advance workflow run %s: %v", w.ID, nodeRun.WorkflowRunID, err)
+	}
+}